@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cluster-engine/provisioner/capv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var addonsCluster string
+
+var addonsCmd = &cobra.Command{
+	Use:   "addons",
+	Short: "Manage the addons of a CAPV management cluster",
+	Long:  `Manage the addons of a CAPV management cluster using the permanent kubeconfig written by capv-deploy`,
+}
+
+var addonsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available addons",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := addonsMgmtCluster(false)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tENABLED\tDEPENDS ON")
+		for _, a := range cluster.ListAddons() {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", a.Name(), a.Enabled(), strings.Join(a.DependsOn(), ","))
+		}
+		w.Flush()
+	},
+}
+
+var addonsStatusCmd = &cobra.Command{
+	Use:   "status [addon...]",
+	Short: "Report the health of the enabled addons, or of the named addons",
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := addonsMgmtCluster(true)
+		var addons []capv.Addon
+		if len(args) == 0 {
+			for _, a := range cluster.ListAddons() {
+				if a.Enabled() {
+					addons = append(addons, a)
+				}
+			}
+		}
+		for _, name := range args {
+			a, err := cluster.GetAddon(name)
+			if err != nil {
+				log.Fatalf(err.Error())
+			}
+			addons = append(addons, a)
+		}
+
+		unhealthy := false
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tINSTALLED\tHEALTHY\tDETAILS")
+		for _, a := range addons {
+			status, err := a.Status()
+			if err != nil {
+				status.Details = err.Error()
+			}
+			if !status.Healthy {
+				unhealthy = true
+			}
+			details := strings.Split(strings.TrimSpace(status.Details), "\n")
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", a.Name(), status.Installed, status.Healthy, details[0])
+			for _, d := range details[1:] {
+				fmt.Fprintf(w, "\t\t\t%v\n", d)
+			}
+		}
+		w.Flush()
+		if unhealthy {
+			os.Exit(1)
+		}
+	},
+}
+
+var addonsInstallCmd = &cobra.Command{
+	Use:   "install <addon>",
+	Short: "Install or reinstall a single addon without touching the cluster",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := addonsMgmtCluster(true)
+		err := cluster.InstallAddon(args[0])
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		log.Infof("%v addon installed.", args[0])
+	},
+}
+
+var addonsUninstallCmd = &cobra.Command{
+	Use:   "uninstall <addon>",
+	Short: "Uninstall a single addon",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cluster := addonsMgmtCluster(true)
+		err := cluster.UninstallAddon(args[0])
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		log.Infof("%v addon uninstalled.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addonsCmd)
+	addonsCmd.PersistentFlags().StringVar(&addonsCluster, "cluster", "", "name of the management cluster (default is ClusterName from the config)")
+	addonsCmd.AddCommand(addonsListCmd, addonsStatusCmd, addonsInstallCmd, addonsUninstallCmd)
+}
+
+// addonsMgmtCluster loads the cluster config and logs its events,
+// requireKubeconfig checks the permanent kubeconfig from capv-deploy exists
+func addonsMgmtCluster(requireKubeconfig bool) *capv.MgmtCluster {
+	C := capv.MgmtCluster{}
+	err := viper.UnmarshalExact(&C)
+	if err != nil {
+		log.Fatalf("unable to decode into struct, %v", err.Error())
+	}
+	if addonsCluster != "" {
+		C.ClusterName = addonsCluster
+	}
+	if requireKubeconfig {
+		if C.ClusterName == "" {
+			log.Fatalf("a cluster name is required, set --cluster or ClusterName in the config")
+		}
+		home, err := homedir.Dir()
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		kubeconfig := filepath.Join(home, capv.ConfigDir, C.ClusterName, "kubeconfig")
+		if _, err := os.Stat(kubeconfig); err != nil {
+			log.Fatalf("permanent kubeconfig for cluster %v not found, %v", C.ClusterName, err)
+		}
+	}
+
+	cluster := capv.NewMgmtCluster(C).(*capv.MgmtCluster)
+	go func() {
+		for event := range cluster.Events() {
+			e := event.(capv.Event)
+			log.WithFields(log.Fields{
+				"eventType": e.EventType,
+				"event":     e.Event,
+			}).Info("event received")
+		}
+	}()
+	return cluster
+}
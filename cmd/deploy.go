@@ -19,6 +19,7 @@ import (
 
 var (
 	logLevel string
+	dryRun   bool
 	//cfgFile                         string
 	controlPlaneMachineCount        int
 	workerMachineCount              int
@@ -46,6 +47,7 @@ type progress struct {
 
 func init() {
 	rootCmd.AddCommand(capvDeployCmd)
+	capvDeployCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the rendered cluster spec without creating the permanent cluster, the bootstrap cluster is deleted afterwards")
 	responseBody = new(progress)
 	responseBody.Messages = []string{}
 }
@@ -147,6 +149,16 @@ func runCapvProvisioner(controlPlaneMachineCount, workerMachineCount int) {
 	log.Info("CAPv installed successfully.")
 	responseBody.Messages = append(responseBody.Messages, "CAPv installed successfully")
 
+	if dryRun {
+		spec, err := cluster.DryRun()
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+		fmt.Println(string(spec))
+		log.Info("Dry run complete, the bootstrap cluster was deleted.")
+		return
+	}
+
 	log.Info("Creating permanent management cluster...")
 	err = cluster.CreatePermanent()
 	if err != nil {
@@ -13,7 +13,11 @@ require (
 	github.com/vmware/govmomi v0.22.2
 	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
 	gopkg.in/yaml.v3 v3.0.0-20200121175148-a6ecf24a6d71
+	helm.sh/helm/v3 v3.1.3
 	k8s.io/api v0.17.2
+	k8s.io/apimachinery v0.17.2
+	k8s.io/cli-runtime v0.17.2
 	sigs.k8s.io/cluster-api v0.3.3
 	sigs.k8s.io/cluster-api-provider-vsphere v0.6.3
+	sigs.k8s.io/yaml v1.2.0
 )
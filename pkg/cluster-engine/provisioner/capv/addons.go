@@ -1,179 +1,228 @@
 package capv
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
-	"github.com/netapp/cake/pkg/cmds"
 	"golang.org/x/sync/errgroup"
 )
 
-const (
-	specWithTrident = "%s-final.yaml"
-)
-
-// InstallAddons installs any optional Addons to a management cluster
-func (m *MgmtCluster) InstallAddons() error {
-	var g errgroup.Group
+// Addon is an optional component installed into the permanent management cluster
+type Addon interface {
+	Name() string
+	// Enabled reports whether the addon is turned on in the config
+	Enabled() bool
+	// DependsOn lists the addons that must be installed first, disabled dependencies are ignored
+	DependsOn() []string
+	RequiredCommands() []string
+	Validate() error
+	Install() error
+	Uninstall() error
+	Status() (AddonStatus, error)
+}
 
-	g.Go(func() error {
-		if m.Addons.Solidfire.Enable {
-			return installTrident(m)
-		}
-		return nil
-	})
-	g.Go(func() error {
-		if m.Addons.Observability.Enable {
+// AddonStatus is the health of an installed addon
+type AddonStatus struct {
+	Installed bool
+	Healthy   bool
+	Details   string
+}
 
-			return installObservability(m)
-		}
-		return nil
-	})
+// AddonFactory creates an addon from the management cluster config
+type AddonFactory func(m *MgmtCluster) Addon
 
-	return g.Wait()
-}
+var addonRegistry = map[string]AddonFactory{}
 
-func installObservability(m *MgmtCluster) error {
-	m.events <- Event{EventType: "progress", Event: "installing the observability addon"}
-	var err error
-
-	//targetDir, err := extractLocalArchive(m, dir)
-	/*
-		check if there is a default storage class, if not install longhorn
-		kubectl apply -f https://raw.githubusercontent.com/longhorn/longhorn/master/deploy/longhorn.yaml
-		kubectl create -f https://raw.githubusercontent.com/longhorn/longhorn/master/examples/storageclass.yaml
-		kubectl patch storageclass longhorn -p '{"metadata": {"annotations":{"storageclass.kubernetes.io/is-default-class":"true"}}}'
-	*/
-
-	// create alias from helm to helm3
-
-	/*
-		helm3 repo add stable https://kubernetes-charts.storage.googleapis.com
-		helm3 repo add loki https://grafana.github.io/loki/charts
-		kubectl create ns nks-system
-		helm3 install -n nks-system prometheus stable/prometheus
-		helm3 install -n nks-system loki loki/loki-stack
-	*/
-
-	/*
-		cd patch
-		sed -i 's/prometheus.nks-system.svc.cluster.local:8080/prometheus-server.nks-system.svc.cluster.local/g' grafana/grafana-values.yaml
-		make all
-	*/
-	m.events <- Event{EventType: "progress", Event: "observability addon install complete"}
-	return err
+// RegisterAddon makes an addon available to InstallAddons, it is meant to be called from init()
+func RegisterAddon(name string, factory AddonFactory) {
+	if _, ok := addonRegistry[name]; ok {
+		panic("addon already registered: " + name)
+	}
+	addonRegistry[name] = factory
 }
 
-func installTrident(m *MgmtCluster) error {
-	m.events <- Event{EventType: "progress", Event: "installing the trident addon"}
-	var err error
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
+// ListAddons returns every registered addon sorted by name
+func (m *MgmtCluster) ListAddons() []Addon {
+	var names []string
+	for name := range addonRegistry {
+		names = append(names, name)
 	}
-	permanentKubeConfig := filepath.Join(home, ConfigDir, m.ClusterName, "kubeconfig")
-	envs := map[string]string{
-		"KUBECONFIG": permanentKubeConfig,
-	}
-	args := []string{"install", "--namespace=trident"}
-	err = cmds.GenericExecute(envs, string(tridentctl), args, nil)
-	if err != nil {
-		return err
+	sort.Strings(names)
+	var result []Addon
+	for _, name := range names {
+		result = append(result, addonRegistry[name](m))
 	}
+	return result
+}
 
-	backend := fmt.Sprintf(
-		elementBackendJSON.Contents,
-		m.Addons.Solidfire.User,
-		m.Addons.Solidfire.Password,
-		m.Addons.Solidfire.MVIP,
-		m.Addons.Solidfire.SVIP,
-		m.ClusterName,
-	)
-	err = writeToDisk(m.ClusterName, elementBackendJSON.Name, []byte(backend), 0644)
-	if err != nil {
-		return err
+// enabledAddons returns the enabled addons keyed by name
+func (m *MgmtCluster) enabledAddons() map[string]Addon {
+	enabled := map[string]Addon{}
+	for _, a := range m.ListAddons() {
+		if a.Enabled() {
+			enabled[a.Name()] = a
+		}
 	}
+	return enabled
+}
 
-	fpath := filepath.Join(home, ConfigDir, m.ClusterName, elementBackendJSON.Name)
-	args = []string{
-		"--namespace=trident",
-		"create",
-		"backend",
-		"--filename=" + fpath,
-	}
-	err = cmds.GenericExecute(envs, string(tridentctl), args, nil)
-	if err != nil {
-		return err
+// InstallAddons installs any optional Addons to a management cluster
+func (m *MgmtCluster) InstallAddons() error {
+	enabled := m.enabledAddons()
+	for name, a := range enabled {
+		err := a.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %v addon config, %v", name, err)
+		}
 	}
-
-	err = writeToDisk(m.ClusterName, elementStorageClass.Name, []byte(elementStorageClass.Contents), 0644)
+	err := checkAddonDependencies(enabled)
 	if err != nil {
 		return err
 	}
-	fpath = filepath.Join(home, ConfigDir, m.ClusterName, elementStorageClass.Name)
 
-	args = []string{
-		"--namespace=default",
-		"--output=json",
-		"apply",
-		"--filename=" + fpath,
+	// each addon waits on its dependencies, independent addons run in parallel
+	done := map[string]chan struct{}{}
+	failed := map[string]*bool{}
+	for name := range enabled {
+		done[name] = make(chan struct{})
+		failed[name] = new(bool)
 	}
-	err = cmds.GenericExecute(envs, string(kubectl), args, nil)
-	if err != nil {
-		return err
+	var g errgroup.Group
+	for name, a := range enabled {
+		name, a := name, a
+		g.Go(func() error {
+			defer close(done[name])
+			for _, dep := range a.DependsOn() {
+				if _, ok := enabled[dep]; !ok {
+					continue
+				}
+				<-done[dep]
+				if *failed[dep] {
+					*failed[name] = true
+					return fmt.Errorf("%v addon not installed, dependency %v failed", name, dep)
+				}
+			}
+			err := a.Install()
+			if err != nil {
+				*failed[name] = true
+				return fmt.Errorf("%v addon install failed, %v", name, err)
+			}
+			return nil
+		})
 	}
-	m.events <- Event{EventType: "progress", Event: "trident addon install complete"}
-	return err
-}
 
-// injectTridentPrereqs runs a `kubectl kustomize` command to inject trident into CAPI machines
-func injectTridentPrereqs(clusterName, storageNetwork, kubeconfigLocation string, ctx *context.Context) error {
-	var err error
-	var envs map[string]string
+	return g.Wait()
+}
 
-	kf := fmt.Sprintf(KustomizationFile.Contents, clusterName, clusterName+"-md-0")
-	err = writeToDisk(clusterName, KustomizationFile.Name, []byte(kf), 0644)
-	if err != nil {
-		return err
+// GetAddon returns the registered addon with the given name
+func (m *MgmtCluster) GetAddon(name string) (Addon, error) {
+	factory, ok := addonRegistry[name]
+	if !ok {
+		var names []string
+		for _, a := range m.ListAddons() {
+			names = append(names, a.Name())
+		}
+		return nil, fmt.Errorf("unknown addon: %v, must be one of: %v", name, strings.Join(names, ", "))
 	}
-	po := fmt.Sprintf(PatchFileOne.Contents, storageNetwork)
-	err = writeToDisk(clusterName, PatchFileOne.Name, []byte(po), 0644)
+	return factory(m), nil
+}
+
+// InstallAddon installs or reinstalls a single enabled addon, its enabled dependencies must already be installed
+func (m *MgmtCluster) InstallAddon(name string) error {
+	a, err := m.GetAddon(name)
 	if err != nil {
 		return err
 	}
-	err = writeToDisk(clusterName, PatchFileTwo.Name, []byte(PatchFileTwo.Contents), 0644)
-	if err != nil {
-		return err
+	if !a.Enabled() {
+		return fmt.Errorf("%v addon is not enabled in the config", name)
 	}
-
-	err = writeToDisk(clusterName, PatchFileThree.Name, []byte(PatchFileThree.Contents), 0644)
+	err = a.Validate()
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid %v addon config, %v", name, err)
 	}
-
-	if kubeconfigLocation != "" {
-		envs = map[string]string{"KUBECONFIG": kubeconfigLocation}
+	// like InstallAddons, dependencies that are not enabled are skipped
+	for _, dep := range a.DependsOn() {
+		d, err := m.GetAddon(dep)
+		if err != nil {
+			return fmt.Errorf("%v addon depends on unknown addon %v", name, dep)
+		}
+		if !d.Enabled() {
+			continue
+		}
+		status, err := d.Status()
+		if err != nil {
+			return fmt.Errorf("unable to get the status of the %v addon, %v", dep, err)
+		}
+		if !status.Installed {
+			return fmt.Errorf("%v addon depends on %v, install it first", name, dep)
+		}
 	}
+	return a.Install()
+}
 
-	home, err := os.UserHomeDir()
+// UninstallAddon uninstalls a single addon
+func (m *MgmtCluster) UninstallAddon(name string) error {
+	a, err := m.GetAddon(name)
 	if err != nil {
 		return err
 	}
-	loc := filepath.Join(home, ConfigDir, clusterName)
-	args := []string{"kustomize", loc}
+	return a.Uninstall()
+}
 
-	c := cmds.NewCommandLine(envs, string(kubectl), args, ctx)
+// checkAddonDependencies errors on unknown dependencies and dependency cycles
+func checkAddonDependencies(enabled map[string]Addon) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("addon dependency cycle: %v", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range enabled[name].DependsOn() {
+			if _, ok := addonRegistry[dep]; !ok {
+				return fmt.Errorf("%v addon depends on unknown addon %v", name, dep)
+			}
+			if _, ok := enabled[dep]; !ok {
+				continue
+			}
+			err := visit(dep, append(path, name))
+			if err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
 
-	stdout, stderr, err := c.Program().Execute()
-	if err != nil || string(stderr) != "" {
-		return fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	var names []string
+	for name := range enabled {
+		names = append(names, name)
 	}
-	err = writeToDisk(clusterName, fmt.Sprintf(specWithTrident, clusterName), stdout, 0644)
-	if err != nil {
-		return err
+	sort.Strings(names)
+	for _, name := range names {
+		err := visit(name, nil)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return err
+// permanentEnvs points kubectl and friends at the permanent management cluster
+func (m *MgmtCluster) permanentEnvs() map[string]string {
+	home, _ := os.UserHomeDir()
+	return map[string]string{
+		"KUBECONFIG": filepath.Join(home, ConfigDir, m.ClusterName, "kubeconfig"),
+	}
 }
@@ -1,9 +1,12 @@
 package capv
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -43,13 +46,254 @@ func shutdown() {
 }
 
 func TestExec(t *testing.T) {
-	err := injectTridentPrereqs(clusterName, "test", "", nil)
+	err := renderClusterSpec(clusterName, tridentPrereqPatches(clusterName, "test", []string{clusterName}), "", nil)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
 	// TODO add tests here
 }
 
+type fakeAddon struct {
+	name      string
+	deps      []string
+	installed *[]string
+	mu        *sync.Mutex
+	fail      bool
+	disabled  bool
+}
+
+func (f *fakeAddon) Name() string               { return f.name }
+func (f *fakeAddon) Enabled() bool              { return !f.disabled }
+func (f *fakeAddon) DependsOn() []string        { return f.deps }
+func (f *fakeAddon) RequiredCommands() []string { return nil }
+func (f *fakeAddon) Validate() error            { return nil }
+func (f *fakeAddon) Uninstall() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var remaining []string
+	for _, name := range *f.installed {
+		if name != f.name {
+			remaining = append(remaining, name)
+		}
+	}
+	*f.installed = remaining
+	return nil
+}
+func (f *fakeAddon) Status() (AddonStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range *f.installed {
+		if name == f.name {
+			return AddonStatus{Installed: true, Healthy: true}, nil
+		}
+	}
+	return AddonStatus{}, nil
+}
+func (f *fakeAddon) Install() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return fmt.Errorf("%v failed", f.name)
+	}
+	*f.installed = append(*f.installed, f.name)
+	return nil
+}
+
+func withFakeAddons(t *testing.T, addons map[string][]string, failing string, disabled ...string) *[]string {
+	saved := addonRegistry
+	t.Cleanup(func() { addonRegistry = saved })
+	addonRegistry = map[string]AddonFactory{}
+	installed := &[]string{}
+	mu := &sync.Mutex{}
+	for name, deps := range addons {
+		a := &fakeAddon{name: name, deps: deps, installed: installed, mu: mu, fail: name == failing}
+		for _, d := range disabled {
+			a.disabled = a.disabled || d == name
+		}
+		addonRegistry[name] = func(m *MgmtCluster) Addon { return a }
+	}
+	return installed
+}
+
+func TestInstallAddonsOrder(t *testing.T) {
+	installed := withFakeAddons(t, map[string][]string{
+		"storage":       nil,
+		"observability": {"storage"},
+		"dashboard":     {"observability", "storage"},
+	}, "")
+	m := &MgmtCluster{}
+	err := m.InstallAddons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"storage", "observability", "dashboard"}
+	if fmt.Sprint(*installed) != fmt.Sprint(expected) {
+		t.Fatalf("expected install order %v, got %v", expected, *installed)
+	}
+}
+
+func TestInstallAddonsFailedDependency(t *testing.T) {
+	installed := withFakeAddons(t, map[string][]string{
+		"storage":       nil,
+		"observability": {"storage"},
+	}, "storage")
+	m := &MgmtCluster{}
+	err := m.InstallAddons()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(*installed) != 0 {
+		t.Fatalf("expected nothing installed, got %v", *installed)
+	}
+}
+
+func TestInstallAddonsCycle(t *testing.T) {
+	withFakeAddons(t, map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"a"},
+	}, "")
+	m := &MgmtCluster{}
+	err := m.InstallAddons()
+	if err == nil {
+		t.Fatal("expected a dependency cycle error")
+	}
+}
+
+func TestGetAddon(t *testing.T) {
+	withFakeAddons(t, map[string][]string{
+		"storage":       nil,
+		"observability": {"storage"},
+	}, "", "observability")
+	tests := map[string]struct {
+		name    string
+		wantErr bool
+	}{
+		"registered":          {"storage", false},
+		"registered disabled": {"observability", false},
+		"unknown":             {"dashboard", true},
+		"empty":               {"", true},
+	}
+	m := &MgmtCluster{}
+	for desc, tt := range tests {
+		a, err := m.GetAddon(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: got err %v, wantErr %v", desc, err, tt.wantErr)
+			continue
+		}
+		if err == nil && a.Name() != tt.name {
+			t.Errorf("%v: got addon %v, want %v", desc, a.Name(), tt.name)
+		}
+	}
+}
+
+func TestInstallAddon(t *testing.T) {
+	tests := map[string]struct {
+		addons    map[string][]string
+		failing   string
+		disabled  []string
+		installed []string
+		name      string
+		wantErr   string
+		expected  []string
+	}{
+		"no dependencies": {
+			addons:   map[string][]string{"storage": nil},
+			name:     "storage",
+			expected: []string{"storage"},
+		},
+		"unknown addon": {
+			addons:  map[string][]string{"storage": nil},
+			name:    "dashboard",
+			wantErr: "unknown addon",
+		},
+		"disabled addon": {
+			addons:   map[string][]string{"storage": nil},
+			disabled: []string{"storage"},
+			name:     "storage",
+			wantErr:  "not enabled",
+		},
+		"dependency installed": {
+			addons:    map[string][]string{"storage": nil, "observability": {"storage"}},
+			installed: []string{"storage"},
+			name:      "observability",
+			expected:  []string{"storage", "observability"},
+		},
+		"missing dependency": {
+			addons:  map[string][]string{"storage": nil, "observability": {"storage"}},
+			name:    "observability",
+			wantErr: "install it first",
+		},
+		"unknown dependency": {
+			addons:  map[string][]string{"observability": {"storage"}},
+			name:    "observability",
+			wantErr: "unknown addon storage",
+		},
+		"disabled dependency": {
+			addons:   map[string][]string{"storage": nil, "observability": {"storage"}},
+			disabled: []string{"storage"},
+			name:     "observability",
+			expected: []string{"observability"},
+		},
+		"install fails": {
+			addons:  map[string][]string{"storage": nil},
+			failing: "storage",
+			name:    "storage",
+			wantErr: "storage failed",
+		},
+	}
+	for desc, tt := range tests {
+		t.Run(desc, func(t *testing.T) {
+			installed := withFakeAddons(t, tt.addons, tt.failing, tt.disabled...)
+			*installed = append(*installed, tt.installed...)
+			m := &MgmtCluster{}
+			err := m.InstallAddon(tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(*installed) != fmt.Sprint(tt.expected) {
+				t.Fatalf("expected installed %v, got %v", tt.expected, *installed)
+			}
+		})
+	}
+}
+
+func TestUninstallAddon(t *testing.T) {
+	tests := map[string]struct {
+		disabled []string
+		name     string
+		wantErr  bool
+		expected []string
+	}{
+		"installed":      {name: "storage", expected: []string{"observability"}},
+		"disabled addon": {name: "storage", disabled: []string{"storage"}, expected: []string{"observability"}},
+		"unknown addon":  {name: "dashboard", wantErr: true, expected: []string{"storage", "observability"}},
+	}
+	for desc, tt := range tests {
+		t.Run(desc, func(t *testing.T) {
+			installed := withFakeAddons(t, map[string][]string{
+				"storage":       nil,
+				"observability": {"storage"},
+			}, "", tt.disabled...)
+			*installed = append(*installed, "storage", "observability")
+			m := &MgmtCluster{}
+			err := m.UninstallAddon(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(*installed) != fmt.Sprint(tt.expected) {
+				t.Fatalf("expected installed %v, got %v", tt.expected, *installed)
+			}
+		})
+	}
+}
+
 const baseYaml = `apiVersion: cluster.x-k8s.io/v1alpha3
 kind: Cluster
 metadata:
@@ -2,45 +2,294 @@ package capv
 
 import (
 	"fmt"
-	"time"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/mitchellh/go-homedir"
 	"github.com/netapp/cake/pkg/cmds"
+	"gopkg.in/yaml.v3"
 )
 
+// kindCluster is the kind.x-k8s.io/v1alpha4 Cluster config
+type kindCluster struct {
+	Kind                    string     `yaml:"kind"`
+	APIVersion              string     `yaml:"apiVersion"`
+	Nodes                   []kindNode `yaml:"nodes"`
+	ContainerdConfigPatches []string   `yaml:"containerdConfigPatches,omitempty"`
+}
+
+type kindNode struct {
+	Role              string            `yaml:"role"`
+	Image             string            `yaml:"image,omitempty"`
+	ExtraMounts       []kindMount       `yaml:"extraMounts,omitempty"`
+	ExtraPortMappings []kindPortMapping `yaml:"extraPortMappings,omitempty"`
+}
+
+type kindMount struct {
+	HostPath      string `yaml:"hostPath"`
+	ContainerPath string `yaml:"containerPath"`
+	ReadOnly      bool   `yaml:"readOnly,omitempty"`
+}
+
+type kindPortMapping struct {
+	ContainerPort int32  `yaml:"containerPort"`
+	HostPort      int32  `yaml:"hostPort,omitempty"`
+	ListenAddress string `yaml:"listenAddress,omitempty"`
+	Protocol      string `yaml:"protocol,omitempty"`
+}
+
+// runCommand runs name with args and returns its output, tests swap it for a fake
+var runCommand = func(envs map[string]string, name string, args []string) ([]byte, []byte, error) {
+	return cmds.NewCommandLine(envs, name, args, nil).Program().Execute()
+}
+
+// executeCommand runs name with args streaming its output, tests swap it for a fake
+var executeCommand = cmds.GenericExecute
+
 // CreateBootstrap creates the temporary CAPv bootstrap cluster
 func (m *MgmtCluster) CreateBootstrap() error {
+	switch m.Bootstrap.Provider {
+	case "", bootstrapProviderKind:
+		return m.createKindBootstrap()
+	case bootstrapProviderExisting:
+		return m.useExistingBootstrap()
+	}
+	return fmt.Errorf("unknown bootstrap provider: %v", m.Bootstrap.Provider)
+}
+
+// createKindBootstrap creates a kind cluster named after the management cluster
+func (m *MgmtCluster) createKindBootstrap() error {
 	var err error
 
-	m.events <- Event{EventType: "progress", Event: "kind create cluster (bootstrap cluster)"}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	exists, err := kindClusterExists(m.ClusterName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("kind cluster %v already exists", m.ClusterName)
+	}
 
+	config, err := generateKindConfig(m.Bootstrap.Kind)
+	if err != nil {
+		return err
+	}
+	err = writeToDisk(m.ClusterName, kindConfig, config, 0644)
+	if err != nil {
+		return err
+	}
+
+	m.events <- Event{EventType: "progress", Event: "kind create cluster (bootstrap cluster)"}
+	kubeConfig := filepath.Join(home, ConfigDir, m.ClusterName, bootstrapKubeconfig)
 	args := []string{
 		"create",
 		"cluster",
+		"--name=" + m.ClusterName,
+		"--config=" + filepath.Join(home, ConfigDir, m.ClusterName, kindConfig),
+		"--kubeconfig=" + kubeConfig,
+		"--wait=5m",
 	}
-	err = cmds.GenericExecute(nil, string(kind), args, nil)
+	err = cmds.GenericExecute(kindProxyEnvs(m.Bootstrap.Kind.Proxy), string(kind), args, nil)
 	if err != nil {
 		return err
 	}
+	m.events <- Event{EventType: "progress", Event: "bootstrap cluster kubeconfig written to " + kubeConfig}
+
+	return err
+}
 
-	m.events <- Event{EventType: "progress", Event: "getting and writing bootstrap cluster kubeconfig to disk"}
+// useExistingBootstrap uses the cluster in the supplied kubeconfig as the bootstrap cluster
+func (m *MgmtCluster) useExistingBootstrap() error {
+	var err error
+
+	if m.Kubeconfig == "" {
+		return fmt.Errorf("a Kubeconfig is required for the %v bootstrap provider", bootstrapProviderExisting)
+	}
+	location, err := homedir.Expand(m.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadFile(location)
+	if err != nil {
+		return fmt.Errorf("unable to read bootstrap kubeconfig, %v", err)
+	}
+
+	m.events <- Event{EventType: "progress", Event: "using existing cluster from " + location + " as the bootstrap cluster"}
+	err = writeToDisk(m.ClusterName, bootstrapKubeconfig, contents, 0644)
+	if err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	envs := map[string]string{
+		"KUBECONFIG": filepath.Join(home, ConfigDir, m.ClusterName, bootstrapKubeconfig),
+	}
+
+	m.events <- Event{EventType: "progress", Event: "checking bootstrap cluster RBAC"}
+	args := []string{
+		"auth",
+		"can-i",
+		"*",
+		"*",
+		"--all-namespaces",
+	}
+	stdout, stderr, err := runCommand(envs, string(kubectl), args)
+	if err != nil || strings.TrimSpace(string(stdout)) != "yes" {
+		return fmt.Errorf("cluster-admin permissions are required in the bootstrap cluster, err: %v, stderr: %v", err, string(stderr))
+	}
+
+	m.events <- Event{EventType: "progress", Event: "checking bootstrap cluster for an existing cluster API installation"}
 	args = []string{
 		"get",
-		"kubeconfig",
+		"customresourcedefinitions",
+		"--ignore-not-found",
+		"--output=name",
+		"clusters.cluster.x-k8s.io",
+		"providers.clusterctl.cluster.x-k8s.io",
 	}
-	c := cmds.NewCommandLine(nil, string(kind), args, nil)
-	stdout, stderr, err := c.Program().Execute()
+	stdout, stderr, err = runCommand(envs, string(kubectl), args)
 	if err != nil || string(stderr) != "" {
 		return fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
 	}
+	if strings.TrimSpace(string(stdout)) != "" {
+		return fmt.Errorf("cluster API is already installed in the bootstrap cluster, found: %v", strings.Join(strings.Fields(string(stdout)), ", "))
+	}
 
-	err = writeToDisk(m.ClusterName, bootstrapKubeconfig, []byte(stdout), 0644)
+	return err
+}
+
+// DeleteBootstrap deletes the kind bootstrap cluster, an existing bootstrap cluster is kept
+// and only the cluster API components are removed from it
+func (m *MgmtCluster) DeleteBootstrap() error {
+	switch m.Bootstrap.Provider {
+	case "", bootstrapProviderKind:
+		m.events <- Event{EventType: "progress", Event: "kind delete cluster (bootstrap cluster)"}
+		args := []string{
+			"delete",
+			"cluster",
+			"--name=" + m.ClusterName,
+		}
+		return executeCommand(nil, string(kind), args, nil)
+	case bootstrapProviderExisting:
+		return m.removeBootstrapCapi()
+	}
+	return fmt.Errorf("unknown bootstrap provider: %v", m.Bootstrap.Provider)
+}
+
+// cleanupBootstrap removes cluster API from the bootstrap cluster after the pivot, only when the
+// bootstrap cluster is an existing one and RemoveCapiAfterPivot is set
+func (m *MgmtCluster) cleanupBootstrap() error {
+	if m.Bootstrap.Provider != bootstrapProviderExisting || !m.Bootstrap.RemoveCapiAfterPivot {
+		return nil
+	}
+	return m.removeBootstrapCapi()
+}
+
+// removeBootstrapCapi deletes the cluster API components from the bootstrap cluster
+func (m *MgmtCluster) removeBootstrapCapi() error {
+	home, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
 
-	// TODO wait for cluster components to be running
-	m.events <- Event{EventType: "progress", Event: "sleeping 20 seconds, need to fix this"}
-	time.Sleep(20 * time.Second)
+	m.events <- Event{EventType: "progress", Event: "removing cluster API components from the bootstrap cluster"}
+	envs := map[string]string{
+		"KUBECONFIG": filepath.Join(home, ConfigDir, m.ClusterName, bootstrapKubeconfig),
+	}
+	args := []string{
+		"delete",
+		"--all",
+		"--include-crd",
+		"--include-namespace",
+	}
+	return executeCommand(envs, string(clusterctl), args, nil)
+}
 
-	return err
+// kindClusterExists checks if a kind cluster with the given name is already running
+func kindClusterExists(name string) (bool, error) {
+	args := []string{
+		"get",
+		"clusters",
+	}
+	stdout, _, err := runCommand(nil, string(kind), args)
+	if err != nil {
+		return false, fmt.Errorf("unable to list kind clusters, %v", err)
+	}
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if strings.TrimSpace(line) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// generateKindConfig renders the kind cluster config for the bootstrap cluster
+func generateKindConfig(k Kind) ([]byte, error) {
+	node := kindNode{
+		Role:  "control-plane",
+		Image: k.NodeImage,
+	}
+	for _, mount := range k.ExtraMounts {
+		node.ExtraMounts = append(node.ExtraMounts, kindMount{
+			HostPath:      mount.HostPath,
+			ContainerPath: mount.ContainerPath,
+			ReadOnly:      mount.ReadOnly,
+		})
+	}
+	for _, port := range k.PortMappings {
+		node.ExtraPortMappings = append(node.ExtraPortMappings, kindPortMapping{
+			ContainerPort: port.ContainerPort,
+			HostPort:      port.HostPort,
+			ListenAddress: port.ListenAddress,
+			Protocol:      port.Protocol,
+		})
+	}
+
+	config := kindCluster{
+		Kind:       "Cluster",
+		APIVersion: "kind.x-k8s.io/v1alpha4",
+		Nodes:      []kindNode{node},
+	}
+	for _, mirror := range k.RegistryMirrors {
+		if mirror.Registry == "" || len(mirror.Endpoints) == 0 {
+			return nil, fmt.Errorf("registry mirror requires a registry and at least one endpoint")
+		}
+		endpoints := make([]string, len(mirror.Endpoints))
+		for i, e := range mirror.Endpoints {
+			endpoints[i] = fmt.Sprintf("%q", e)
+		}
+		config.ContainerdConfigPatches = append(config.ContainerdConfigPatches, fmt.Sprintf(
+			"[plugins.\"io.containerd.grpc.v1.cri\".registry.mirrors.%q]\n  endpoint = [%s]",
+			mirror.Registry,
+			strings.Join(endpoints, ", "),
+		))
+	}
+
+	return yaml.Marshal(config)
+}
+
+// kindProxyEnvs returns the proxy env vars kind passes through to the node containers
+func kindProxyEnvs(p Proxy) map[string]string {
+	envs := map[string]string{}
+	if p.HTTPProxy != "" {
+		envs["HTTP_PROXY"] = p.HTTPProxy
+	}
+	if p.HTTPSProxy != "" {
+		envs["HTTPS_PROXY"] = p.HTTPSProxy
+	}
+	if p.NoProxy != "" {
+		envs["NO_PROXY"] = p.NoProxy
+	}
+	if len(envs) == 0 {
+		return nil
+	}
+	return envs
 }
@@ -0,0 +1,259 @@
+package capv
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateKindConfig(t *testing.T) {
+	k := Kind{
+		NodeImage: "kindest/node:v1.17.0",
+		RegistryMirrors: []RegistryMirror{
+			{Registry: "docker.io", Endpoints: []string{"http://mirror.local:5000"}},
+		},
+		PortMappings: []PortMapping{
+			{ContainerPort: 30080, HostPort: 8080},
+		},
+		ExtraMounts: []Mount{
+			{HostPath: "/opt/images", ContainerPath: "/images", ReadOnly: true},
+		},
+	}
+	config, err := generateKindConfig(k)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"apiVersion: kind.x-k8s.io/v1alpha4",
+		"image: kindest/node:v1.17.0",
+		`[plugins."io.containerd.grpc.v1.cri".registry.mirrors."docker.io"]`,
+		`endpoint = ["http://mirror.local:5000"]`,
+		"containerPort: 30080",
+		"hostPort: 8080",
+		"containerPath: /images",
+		"readOnly: true",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(config), e) {
+			t.Errorf("expected %q in kind config:\n%s", e, config)
+		}
+	}
+}
+
+func TestGenerateKindConfigInvalidMirror(t *testing.T) {
+	k := Kind{
+		RegistryMirrors: []RegistryMirror{{Registry: "docker.io"}},
+	}
+	_, err := generateKindConfig(k)
+	if err == nil {
+		t.Error("expected an error for a registry mirror without endpoints")
+	}
+}
+
+// fakeOutput is what fakeRunner returns for a command
+type fakeOutput struct {
+	stdout string
+	stderr string
+	err    error
+}
+
+// fakeRunner replaces runCommand and executeCommand, it answers each command by its
+// name and first argument, e.g. "kubectl auth", and records every command it ran
+type fakeRunner struct {
+	outputs map[string]fakeOutput
+	ran     []string
+}
+
+func (f *fakeRunner) run(envs map[string]string, name string, args []string) ([]byte, []byte, error) {
+	f.ran = append(f.ran, name+" "+strings.Join(args, " "))
+	out, ok := f.outputs[name+" "+args[0]]
+	if !ok {
+		return nil, nil, errors.New("unexpected command " + name + " " + strings.Join(args, " "))
+	}
+	return []byte(out.stdout), []byte(out.stderr), out.err
+}
+
+func (f *fakeRunner) execute(envs map[string]string, name string, args []string, ctx *context.Context) error {
+	_, _, err := f.run(envs, name, args)
+	return err
+}
+
+// withFakeRunner swaps in f for the command runners and points HOME and the
+// management cluster Kubeconfig at a temp dir
+func withFakeRunner(t *testing.T, m *MgmtCluster, f *fakeRunner) func() {
+	home := os.Getenv("HOME")
+	dir, err := ioutil.TempDir("", "bootstrap_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("HOME", dir)
+	m.Kubeconfig = filepath.Join(dir, "existing.kubeconfig")
+	err = ioutil.WriteFile(m.Kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, execute := runCommand, executeCommand
+	runCommand, executeCommand = f.run, f.execute
+	return func() {
+		runCommand, executeCommand = run, execute
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUseExistingBootstrap(t *testing.T) {
+	cases := []struct {
+		name    string
+		outputs map[string]fakeOutput
+		wantErr string
+	}{
+		{
+			name: "cluster admin without cluster API",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stdout: "yes\n"},
+				"kubectl get":  {},
+			},
+		},
+		{
+			name: "not cluster admin",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stdout: "no\n", err: errors.New("exit status 1")},
+				"kubectl get":  {},
+			},
+			wantErr: "cluster-admin permissions are required",
+		},
+		{
+			name: "RBAC check fails without output",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stderr: "Unable to connect to the server", err: errors.New("exit status 1")},
+				"kubectl get":  {},
+			},
+			wantErr: "cluster-admin permissions are required",
+		},
+		{
+			name: "RBAC check answers yes with an error",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stdout: "yes\n", err: errors.New("exit status 1")},
+				"kubectl get":  {},
+			},
+			wantErr: "cluster-admin permissions are required",
+		},
+		{
+			name: "CRD check fails",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stdout: "yes\n"},
+				"kubectl get":  {err: errors.New("exit status 1")},
+			},
+			wantErr: "exit status 1",
+		},
+		{
+			name: "CRD check writes to stderr",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stdout: "yes\n"},
+				"kubectl get":  {stderr: "Error from server (Forbidden)"},
+			},
+			wantErr: "Forbidden",
+		},
+		{
+			name: "cluster API already installed",
+			outputs: map[string]fakeOutput{
+				"kubectl auth": {stdout: "yes\n"},
+				"kubectl get":  {stdout: "customresourcedefinition.apiextensions.k8s.io/clusters.cluster.x-k8s.io\n"},
+			},
+			wantErr: "cluster API is already installed",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := &MgmtCluster{events: make(chan interface{}, 10)}
+			m.ClusterName = "test"
+			m.Bootstrap.Provider = bootstrapProviderExisting
+			f := &fakeRunner{outputs: c.outputs}
+			defer withFakeRunner(t, m, f)()
+
+			err := m.CreateBootstrap()
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error, %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+			home, _ := os.UserHomeDir()
+			if _, err := os.Stat(filepath.Join(home, ConfigDir, m.ClusterName, bootstrapKubeconfig)); err != nil {
+				t.Errorf("expected the bootstrap kubeconfig to be written, %v", err)
+			}
+		})
+	}
+}
+
+func TestUseExistingBootstrapNoKubeconfig(t *testing.T) {
+	m := &MgmtCluster{events: make(chan interface{}, 10)}
+	m.ClusterName = "test"
+	m.Bootstrap.Provider = bootstrapProviderExisting
+	f := &fakeRunner{}
+	defer withFakeRunner(t, m, f)()
+	m.Kubeconfig = ""
+
+	if err := m.CreateBootstrap(); err == nil {
+		t.Fatal("expected an error without a Kubeconfig")
+	}
+	if len(f.ran) != 0 {
+		t.Errorf("expected no commands to run, ran %v", f.ran)
+	}
+}
+
+func TestCleanupBootstrap(t *testing.T) {
+	cases := []struct {
+		name       string
+		provider   string
+		removeCapi bool
+		wantDelete bool
+	}{
+		{name: "kind", provider: bootstrapProviderKind, removeCapi: true},
+		{name: "default provider", provider: "", removeCapi: true},
+		{name: "existing, keep cluster API", provider: bootstrapProviderExisting},
+		{name: "existing, remove cluster API", provider: bootstrapProviderExisting, removeCapi: true, wantDelete: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := &MgmtCluster{events: make(chan interface{}, 10)}
+			m.ClusterName = "test"
+			m.Bootstrap.Provider = c.provider
+			m.Bootstrap.RemoveCapiAfterPivot = c.removeCapi
+			f := &fakeRunner{outputs: map[string]fakeOutput{"clusterctl delete": {}}}
+			defer withFakeRunner(t, m, f)()
+
+			if err := m.cleanupBootstrap(); err != nil {
+				t.Fatal(err)
+			}
+			if !c.wantDelete {
+				if len(f.ran) != 0 {
+					t.Errorf("expected no commands to run, ran %v", f.ran)
+				}
+				return
+			}
+			if len(f.ran) != 1 || f.ran[0] != "clusterctl delete --all --include-crd --include-namespace" {
+				t.Errorf("expected clusterctl delete --all --include-crd --include-namespace, ran %v", f.ran)
+			}
+		})
+	}
+}
+
+func TestCleanupBootstrapDeleteFails(t *testing.T) {
+	m := &MgmtCluster{events: make(chan interface{}, 10)}
+	m.ClusterName = "test"
+	m.Bootstrap.Provider = bootstrapProviderExisting
+	m.Bootstrap.RemoveCapiAfterPivot = true
+	f := &fakeRunner{outputs: map[string]fakeOutput{"clusterctl delete": {err: errors.New("exit status 1")}}}
+	defer withFakeRunner(t, m, f)()
+
+	if err := m.cleanupBootstrap(); err == nil {
+		t.Fatal("expected the clusterctl delete error")
+	}
+}
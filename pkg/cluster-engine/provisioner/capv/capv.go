@@ -24,7 +24,10 @@ func NewMgmtCluster(clusterConfig MgmtCluster) provisioner.Cluster {
 type MgmtCluster struct {
 	provisioner.MgmtCluster `yaml:",inline" mapstructure:",squash"`
 	Vsphere                 `yaml:",inline" mapstructure:",squash"`
-	Addons                  Addons `yaml:"Addons"`
+	Bootstrap               Bootstrap `yaml:"Bootstrap"`
+	CNI                     CNI       `yaml:"CNI"`
+	Patches                 []Patch   `yaml:"Patches"`
+	Addons                  Addons    `yaml:"Addons"`
 	events                  chan interface{}
 }
 
@@ -39,11 +42,95 @@ type Vsphere struct {
 	VcenterServer     string `yaml:"VcenterServer"`
 	VsphereUsername   string `yaml:"VsphereUsername"`
 	VspherePassword   string `yaml:"VspherePassword"`
+	// VcenterThumbprint pins the vCenter certificate to its SHA-1 thumbprint, it is also set on the VSphereCluster
+	VcenterThumbprint string `yaml:"VcenterThumbprint"`
+	// VcenterCABundle is a PEM file with the CAs that sign the vCenter certificate, it needs VcenterThumbprint
+	// as the CAPV controllers only verify vCenter by its thumbprint
+	VcenterCABundle string `yaml:"VcenterCABundle"`
+	// VcenterInsecure skips verification of the vCenter certificate
+	VcenterInsecure bool `yaml:"VcenterInsecure"`
+	// optional subnets of the vSphere networks, checked for overlap with the cluster CIDRs
+	ManagementNetworkCidr string `yaml:"ManagementNetworkCidr"`
+	WorkloadNetworkCidr   string `yaml:"WorkloadNetworkCidr"`
+	StorageNetworkCidr    string `yaml:"StorageNetworkCidr"`
+}
+
+// Bootstrap spec for the temporary bootstrap cluster
+type Bootstrap struct {
+	// Provider is either "kind" (default) or "existing", which uses the cluster in Kubeconfig
+	Provider             string `yaml:"Provider"`
+	Kind                 Kind   `yaml:"Kind"`
+	RemoveCapiAfterPivot bool   `yaml:"RemoveCapiAfterPivot"`
+}
+
+// Kind spec for the kind bootstrap cluster
+type Kind struct {
+	NodeImage       string           `yaml:"NodeImage"`
+	RegistryMirrors []RegistryMirror `yaml:"RegistryMirrors"`
+	Proxy           Proxy            `yaml:"Proxy"`
+	PortMappings    []PortMapping    `yaml:"PortMappings"`
+	ExtraMounts     []Mount          `yaml:"ExtraMounts"`
+}
+
+// RegistryMirror spec for a containerd registry mirror
+type RegistryMirror struct {
+	Registry  string   `yaml:"Registry"`
+	Endpoints []string `yaml:"Endpoints"`
+}
+
+// Proxy spec
+type Proxy struct {
+	HTTPProxy  string `yaml:"HTTPProxy"`
+	HTTPSProxy string `yaml:"HTTPSProxy"`
+	NoProxy    string `yaml:"NoProxy"`
+}
+
+// PortMapping spec for a port exposed from the kind node
+type PortMapping struct {
+	ContainerPort int32  `yaml:"ContainerPort"`
+	HostPort      int32  `yaml:"HostPort"`
+	ListenAddress string `yaml:"ListenAddress"`
+	Protocol      string `yaml:"Protocol"`
+}
+
+// Mount spec for a host path mounted into the kind node
+type Mount struct {
+	HostPath      string `yaml:"HostPath"`
+	ContainerPath string `yaml:"ContainerPath"`
+	ReadOnly      bool   `yaml:"ReadOnly"`
+}
+
+// CNI spec, Provider is one of calico (default), cilium, antrea or flannel
+type CNI struct {
+	Provider string `yaml:"Provider"`
+	Version  string `yaml:"Version"`
+}
+
+// Patch spec for a user supplied kustomize patch against the generated cluster spec
+type Patch struct {
+	// Type is either "strategic" or "json6902"
+	Type   string      `yaml:"Type"`
+	Target PatchTarget `yaml:"Target"`
+	// one of Patch, the inline patch, or Path, a patch file on disk
+	Patch string `yaml:"Patch"`
+	Path  string `yaml:"Path"`
+}
+
+// PatchTarget selects the object a json6902 patch applies to
+type PatchTarget struct {
+	Group   string `yaml:"Group"`
+	Version string `yaml:"Version"`
+	Kind    string `yaml:"Kind"`
+	Name    string `yaml:"Name"`
 }
 
 type Addons struct {
-	Solidfire     Solidfire     `yaml:"Solidfire"`
-	Observability Observability `yaml:"Observability"`
+	Solidfire      Solidfire      `yaml:"Solidfire"`
+	Trident        Trident        `yaml:"Trident"`
+	Observability  Observability  `yaml:"Observability"`
+	DefaultStorage DefaultStorage `yaml:"DefaultStorage"`
+	MetalLB        MetalLB        `yaml:"MetalLB"`
+	Rancher        Rancher        `yaml:"Rancher"`
 }
 
 type Solidfire struct {
@@ -52,11 +139,112 @@ type Solidfire struct {
 	SVIP     string `yaml:"SVIP"`
 	User     string `yaml:"User"`
 	Password string `yaml:"Password"`
+	// APIVersion of the Element API, defaults to 8.0
+	APIVersion string `yaml:"APIVersion"`
+	// SVIPPort defaults to 3260
+	SVIPPort int `yaml:"SVIPPort"`
+	// Tiers default to Bronze, Silver (default) and Gold
+	Tiers []SolidfireTier `yaml:"Tiers"`
+}
+
+// SolidfireTier spec for an Element QoS type and the StorageClass that selects it
+type SolidfireTier struct {
+	Name      string            `yaml:"Name"`
+	MinIOPS   int64             `yaml:"MinIOPS"`
+	MaxIOPS   int64             `yaml:"MaxIOPS"`
+	BurstIOPS int64             `yaml:"BurstIOPS"`
+	FSType    string            `yaml:"FSType"`
+	Labels    map[string]string `yaml:"Labels"`
+	Default   bool              `yaml:"Default"`
+}
+
+// Trident spec for the trident install and ONTAP backends, the Element backend is configured by Solidfire
+type Trident struct {
+	Enable bool `yaml:"Enable"`
+	// Version pins the trident release, tridentctl from $PATH is used when empty
+	Version string `yaml:"Version"`
+	// InstallerLocation overrides the trident installer tarball URL or file for Version
+	InstallerLocation string           `yaml:"InstallerLocation"`
+	Backends          []TridentBackend `yaml:"Backends"`
+	// RemoveCRDs makes uninstall also remove the trident CRDs, which deletes the backends and volume records
+	RemoveCRDs bool `yaml:"RemoveCRDs"`
+}
+
+// TridentBackend spec, Driver is one of ontap-nas, ontap-nas-economy or ontap-san
+type TridentBackend struct {
+	Name           string                `yaml:"Name"`
+	Driver         string                `yaml:"Driver"`
+	ManagementLIF  string                `yaml:"ManagementLIF"`
+	DataLIF        string                `yaml:"DataLIF"`
+	SVM            string                `yaml:"SVM"`
+	Username       string                `yaml:"Username"`
+	Password       string                `yaml:"Password"`
+	StorageClasses []TridentStorageClass `yaml:"StorageClasses"`
+}
+
+// TridentStorageClass spec for a StorageClass provisioned from a single backend
+type TridentStorageClass struct {
+	Name    string `yaml:"Name"`
+	FSType  string `yaml:"FSType"`
+	Default bool   `yaml:"Default"`
 }
 
 type Observability struct {
-	Enable          bool   `yaml:"Enabled"`
+	Enable bool `yaml:"Enabled"`
+	// Namespace the charts are installed in, defaults to nks-system
+	Namespace string `yaml:"Namespace"`
+	// ArchiveLocation is a URL, chart archive or directory holding the prometheus, loki-stack and grafana charts
 	ArchiveLocation string `yaml:"ArchiveLocation"`
+	// ValuesFiles are values file paths keyed by release name: prometheus, loki or grafana
+	ValuesFiles map[string]string `yaml:"ValuesFiles"`
+	// Values are YAML values documents keyed by release name, they are passed to the chart unchanged
+	// and applied after ValuesFiles. Nested config maps would lose the case of their keys.
+	Values map[string]string `yaml:"Values"`
+}
+
+// DefaultStorage spec for the fallback default StorageClass,
+// Provider is one of local-path (default), longhorn or vsphere-csi
+type DefaultStorage struct {
+	Enable   bool   `yaml:"Enable"`
+	Provider string `yaml:"Provider"`
+	// ManifestLocation overrides the longhorn manifest URL or file
+	ManifestLocation string `yaml:"ManifestLocation"`
+	// StoragePolicyName is the vSphere storage policy used by the vsphere-csi StorageClass, optional
+	StoragePolicyName string `yaml:"StoragePolicyName"`
+}
+
+// MetalLB spec for the layer 2 load balancer
+type MetalLB struct {
+	Enable bool `yaml:"Enable"`
+	// Addresses on the workload network, each a CIDR or a first-last range
+	Addresses []string `yaml:"Addresses"`
+	// ManifestLocation overrides the MetalLB v0.9.3 manifest URL or file
+	ManifestLocation string `yaml:"ManifestLocation"`
+}
+
+// Rancher spec for the Rancher server, its ingress needs an ingress controller in the cluster, e.g. ingress-nginx,
+// which is not installed by the rancher addon, and Hostname has to resolve to it
+type Rancher struct {
+	Enable   bool   `yaml:"Enable"`
+	Hostname string `yaml:"Hostname"`
+	// Replicas defaults to 3
+	Replicas int `yaml:"Replicas"`
+	// Version of the rancher chart in the rancher-stable repo, defaults to 2.4.5, newer versions
+	// may need a newer cert-manager than the v0.15.0 installed with it
+	Version string `yaml:"Version"`
+	// TLSSource is one of rancher (self-signed, default), secret or letsEncrypt
+	TLSSource        string `yaml:"TLSSource"`
+	LetsEncryptEmail string `yaml:"LetsEncryptEmail"`
+	// TLSCertificate and TLSKey are the certificate files for the secret TLS source
+	TLSCertificate string `yaml:"TLSCertificate"`
+	TLSKey         string `yaml:"TLSKey"`
+	// CACertificate is the private CA file that signed TLSCertificate
+	CACertificate string `yaml:"CACertificate"`
+	// AirGap requires local charts and CRDs and disallows letsEncrypt
+	AirGap           bool   `yaml:"AirGap"`
+	RancherChart     string `yaml:"RancherChart"`
+	CertManagerChart string `yaml:"CertManagerChart"`
+	CertManagerCRDs  string `yaml:"CertManagerCRDs"`
 }
 
 // Event spec
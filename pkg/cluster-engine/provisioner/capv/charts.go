@@ -0,0 +1,273 @@
+package capv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const (
+	releaseValues       = "values-%s.yaml"
+	releaseConfigValues = "values-%s-config.yaml"
+	// helmDir holds the chart repository cache, so the user's helm setup is left alone
+	helmDir = "helm"
+)
+
+// chartRelease is a chart from a local directory or archive installed as a named release
+type chartRelease struct {
+	Name      string
+	Namespace string
+	// Chart is the location of the chart directory or packaged chart, or the chart name in Repo
+	Chart string
+	// Repo is the chart repository URL, and Version the chart version in it, for charts not on disk
+	Repo    string
+	Version string
+	// ValuesFiles are applied in order, followed by Values and then ValuesYAML
+	ValuesFiles []string
+	Values      map[string]interface{}
+	// ValuesYAML is a values document from the config, it is passed to the chart as is
+	ValuesYAML string
+}
+
+// releaseStatus is the state of an installed release
+type releaseStatus struct {
+	Name      string
+	Namespace string
+	Revision  int
+	// Status is the helm release status, e.g. deployed or failed
+	Status       string
+	ChartVersion string
+}
+
+// chartInstaller installs, tracks and removes chart releases
+type chartInstaller interface {
+	Install(r chartRelease) error
+	Uninstall(name, namespace string) error
+	// Status returns nil when the release is not installed
+	Status(name, namespace string) (*releaseStatus, error)
+}
+
+// newChartInstaller returns the chart installer for the cluster in the kubeconfig
+func newChartInstaller(clusterName string, envs map[string]string) chartInstaller {
+	kubeconfig := envs["KUBECONFIG"]
+	return &helmSDK{
+		clusterName: clusterName,
+		configuration: func(namespace string) (*action.Configuration, error) {
+			flags := genericclioptions.NewConfigFlags(false)
+			flags.KubeConfig = &kubeconfig
+			flags.Namespace = &namespace
+			cfg := new(action.Configuration)
+			err := cfg.Init(flags, namespace, "secret", log.Debugf)
+			if err != nil {
+				return nil, fmt.Errorf("unable to connect to the cluster in %v, %v", kubeconfig, err)
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// helmSDK installs charts with the helm library, releases are tracked by helm in the cluster
+type helmSDK struct {
+	clusterName string
+	// configuration returns the helm configuration for releases in namespace
+	configuration func(namespace string) (*action.Configuration, error)
+}
+
+// settings keeps downloaded charts and repository indexes in the cluster directory
+func (h *helmSDK) settings() (*cli.EnvSettings, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	settings := cli.New()
+	settings.RepositoryConfig = filepath.Join(home, ConfigDir, h.clusterName, helmDir, "repositories.yaml")
+	settings.RepositoryCache = filepath.Join(home, ConfigDir, h.clusterName, helmDir, "cache")
+	return settings, nil
+}
+
+// valuesFiles writes Values and ValuesYAML next to the cluster config and returns every values file of r in order
+func (h *helmSDK) valuesFiles(r chartRelease) ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	files := append([]string{}, r.ValuesFiles...)
+	if len(r.Values) > 0 {
+		contents, err := yaml.Marshal(r.Values)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal values for release %v, %v", r.Name, err)
+		}
+		name := fmt.Sprintf(releaseValues, r.Name)
+		err = writeToDisk(h.clusterName, name, contents, 0644)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, filepath.Join(home, ConfigDir, h.clusterName, name))
+	}
+	if r.ValuesYAML != "" {
+		name := fmt.Sprintf(releaseConfigValues, r.Name)
+		err = writeToDisk(h.clusterName, name, []byte(r.ValuesYAML), 0644)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, filepath.Join(home, ConfigDir, h.clusterName, name))
+	}
+	return files, nil
+}
+
+// Install installs r, or upgrades it when the release already exists
+func (h *helmSDK) Install(r chartRelease) error {
+	cfg, err := h.configuration(r.Namespace)
+	if err != nil {
+		return err
+	}
+	settings, err := h.settings()
+	if err != nil {
+		return err
+	}
+	files, err := h.valuesFiles(r)
+	if err != nil {
+		return err
+	}
+	vals, err := (&values.Options{ValueFiles: files}).MergeValues(getter.All(settings))
+	if err != nil {
+		return fmt.Errorf("unable to read values for release %v, %v", r.Name, err)
+	}
+
+	revisions, err := history(cfg, r.Name)
+	if err != nil {
+		return err
+	}
+	upgrade := len(revisions) > 0
+
+	pathOptions := action.ChartPathOptions{RepoURL: r.Repo, Version: r.Version}
+	location, err := pathOptions.LocateChart(r.Chart, settings)
+	if err != nil {
+		return fmt.Errorf("unable to find chart %v for release %v, %v", r.Chart, r.Name, err)
+	}
+	// Load reads both chart directories and packaged charts
+	chart, err := loader.Load(location)
+	if err != nil {
+		return fmt.Errorf("unable to load chart for release %v, %v", r.Name, err)
+	}
+
+	if upgrade {
+		client := action.NewUpgrade(cfg)
+		client.Namespace = r.Namespace
+		_, err = client.Run(r.Name, chart, vals)
+		if err != nil {
+			return fmt.Errorf("unable to upgrade release %v, %v", r.Name, err)
+		}
+		return nil
+	}
+
+	err = createNamespace(cfg, r.Namespace)
+	if err != nil {
+		return err
+	}
+	client := action.NewInstall(cfg)
+	client.ReleaseName = r.Name
+	client.Namespace = r.Namespace
+	_, err = client.Run(chart, vals)
+	if err != nil {
+		return fmt.Errorf("unable to install release %v, %v", r.Name, err)
+	}
+	return nil
+}
+
+// createNamespace creates the release namespace unless it exists, this helm version does not create it
+func createNamespace(cfg *action.Configuration, namespace string) error {
+	manifest := fmt.Sprintf("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: %v\n", namespace)
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build namespace %v, %v", namespace, err)
+	}
+	var missing kube.ResourceList
+	for _, r := range resources {
+		if r.Get() != nil {
+			missing = append(missing, r)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	_, err = cfg.KubeClient.Create(missing)
+	if err != nil {
+		return fmt.Errorf("unable to create namespace %v, %v", namespace, err)
+	}
+	return nil
+}
+
+// history returns the revisions of release name oldest first, none when it is not installed
+func history(cfg *action.Configuration, name string) ([]*release.Release, error) {
+	revisions, err := action.NewHistory(cfg).Run(name)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the history of release %v, %v", name, err)
+	}
+	releaseutil.SortByRevision(revisions)
+	return revisions, nil
+}
+
+func (h *helmSDK) Uninstall(name, namespace string) error {
+	status, err := h.Status(name, namespace)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		return nil
+	}
+	cfg, err := h.configuration(namespace)
+	if err != nil {
+		return err
+	}
+	_, err = action.NewUninstall(cfg).Run(name)
+	if err != nil {
+		return fmt.Errorf("unable to uninstall release %v, %v", name, err)
+	}
+	return nil
+}
+
+func (h *helmSDK) Status(name, namespace string) (*releaseStatus, error) {
+	cfg, err := h.configuration(namespace)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := history(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	rel := revisions[len(revisions)-1]
+	status := &releaseStatus{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+	}
+	if rel.Info != nil {
+		status.Status = rel.Info.Status.String()
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		status.ChartVersion = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+	}
+	return status, nil
+}
@@ -0,0 +1,156 @@
+package capv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// withFakeHelm returns a helmSDK keeping releases in memory and a chart directory with one template,
+// HOME is a temporary directory until the returned func is called
+func withFakeHelm(t *testing.T) (*helmSDK, *action.Configuration, string, func()) {
+	dir, err := ioutil.TempDir("", "charts_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+
+	chart := filepath.Join(dir, "demo")
+	files := map[string]string{
+		"Chart.yaml":               "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+		"values.yaml":              "replicas: 1\n",
+		"templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: demo\ndata:\n  replicas: \"{{ .Values.replicas }}\"\n",
+	}
+	for name, contents := range files {
+		err = os.MkdirAll(filepath.Dir(filepath.Join(chart, name)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(chart, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &action.Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		Capabilities: chartutil.DefaultCapabilities,
+		Log:          t.Logf,
+	}
+	h := &helmSDK{
+		clusterName: "test",
+		configuration: func(namespace string) (*action.Configuration, error) {
+			return cfg, nil
+		},
+	}
+	return h, cfg, chart, func() {
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestHelmSDKInstall(t *testing.T) {
+	h, cfg, chart, cleanup := withFakeHelm(t)
+	defer cleanup()
+
+	status, err := h.Status("demo", "nks-system")
+	if err != nil || status != nil {
+		t.Fatalf("expected no release, got %+v, %v", status, err)
+	}
+
+	err = h.Install(chartRelease{Name: "demo", Namespace: "nks-system", Chart: chart})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = h.Status("demo", "nks-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == nil || status.Revision != 1 || status.Status != "deployed" || status.ChartVersion != "demo-0.1.0" {
+		t.Fatalf("unexpected release status: %+v", status)
+	}
+
+	// a second install upgrades the release, ValuesYAML keeps the case of its keys
+	err = h.Install(chartRelease{
+		Name:       "demo",
+		Namespace:  "nks-system",
+		Chart:      chart,
+		Values:     map[string]interface{}{"replicas": 2},
+		ValuesYAML: "replicas: 3\nnodeSelector:\n  diskType: ssd\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = h.Status("demo", "nks-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Revision != 2 {
+		t.Fatalf("expected an upgrade to revision 2, got %+v", status)
+	}
+	rel, err := cfg.Releases.Last("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Config["replicas"] != float64(3) {
+		t.Errorf("expected ValuesYAML to override Values, got %v", rel.Config["replicas"])
+	}
+	selector, ok := rel.Config["nodeSelector"].(map[string]interface{})
+	if !ok || selector["diskType"] != "ssd" {
+		t.Errorf("expected the nodeSelector as written, got %v", rel.Config["nodeSelector"])
+	}
+
+	err = h.Uninstall("demo", "nks-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = h.Status("demo", "nks-system")
+	if err != nil || status != nil {
+		t.Fatalf("expected no release after uninstall, got %+v, %v", status, err)
+	}
+	// uninstalling a missing release is not an error
+	err = h.Uninstall("demo", "nks-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHelmSDKInstallArchive(t *testing.T) {
+	h, _, chart, cleanup := withFakeHelm(t)
+	defer cleanup()
+
+	c, err := loader.Load(chart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := chartutil.Save(c, filepath.Dir(chart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.Install(chartRelease{Name: "packaged", Namespace: "nks-system", Chart: archive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := h.Status("packaged", "nks-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == nil || status.ChartVersion != "demo-0.1.0" {
+		t.Fatalf("unexpected release status: %+v", status)
+	}
+
+	err = h.Install(chartRelease{Name: "missing", Namespace: "nks-system", Chart: filepath.Join(filepath.Dir(chart), "missing")})
+	if err == nil {
+		t.Fatal("expected an error for a missing chart")
+	}
+}
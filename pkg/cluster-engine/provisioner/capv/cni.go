@@ -0,0 +1,154 @@
+package capv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/netapp/cake/pkg/cmds"
+)
+
+const (
+	defaultCNI         = "calico"
+	defaultPodCidr     = "192.168.0.0/16"
+	defaultServiceCidr = "10.96.0.0/12"
+)
+
+// cniPlugin is a CNI whose manifests are bundled with the binary
+type cniPlugin struct {
+	defaultVersion string
+	// manifests are text/templates keyed by version
+	manifests map[string]fileOnDisk
+	// namespace and label selector of the per node CNI agent pods
+	namespace string
+	selector  string
+}
+
+var cniPlugins = map[string]cniPlugin{
+	"calico": {
+		defaultVersion: "v3.12",
+		manifests:      map[string]fileOnDisk{"v3.12": calicoManifest},
+		namespace:      "kube-system",
+		selector:       "k8s-app=calico-node",
+	},
+	"cilium": {
+		defaultVersion: "v1.7",
+		manifests:      map[string]fileOnDisk{"v1.7": ciliumManifest},
+		namespace:      "kube-system",
+		selector:       "k8s-app=cilium",
+	},
+	"antrea": {
+		defaultVersion: "v0.6",
+		manifests:      map[string]fileOnDisk{"v0.6": antreaManifest},
+		namespace:      "kube-system",
+		selector:       "component=antrea-agent",
+	},
+	"flannel": {
+		defaultVersion: "v0.12",
+		manifests:      map[string]fileOnDisk{"v0.12": flannelManifest},
+		namespace:      "kube-system",
+		selector:       "app=flannel",
+	},
+}
+
+// cniValues are the values available to the CNI manifest templates
+type cniValues struct {
+	PodCidr     string
+	ServiceCidr string
+}
+
+// getCNIPlugin returns the plugin and manifest for the configured CNI and version
+func getCNIPlugin(c CNI) (cniPlugin, fileOnDisk, error) {
+	name := strings.ToLower(c.Provider)
+	if name == "" {
+		name = defaultCNI
+	}
+	plugin, ok := cniPlugins[name]
+	if !ok {
+		return cniPlugin{}, fileOnDisk{}, fmt.Errorf("unsupported CNI: %v, must be one of: %v", c.Provider, strings.Join(supportedCNIs(), ", "))
+	}
+	version := c.Version
+	if version == "" {
+		version = plugin.defaultVersion
+	}
+	manifest, ok := plugin.manifests[version]
+	if !ok {
+		return cniPlugin{}, fileOnDisk{}, fmt.Errorf("unsupported %v version: %v", name, version)
+	}
+	return plugin, manifest, nil
+}
+
+func supportedCNIs() []string {
+	var names []string
+	for name := range cniPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderCNIManifest templates the CNI manifest with the pod and service CIDRs
+func renderCNIManifest(manifest fileOnDisk, podCidr, serviceCidr string) ([]byte, error) {
+	if podCidr == "" {
+		podCidr = defaultPodCidr
+	}
+	if serviceCidr == "" {
+		serviceCidr = defaultServiceCidr
+	}
+	t, err := template.New(manifest.Name).Parse(manifest.Contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse CNI manifest template, %v", err)
+	}
+	rendered := new(bytes.Buffer)
+	err = t.Execute(rendered, cniValues{PodCidr: podCidr, ServiceCidr: serviceCidr})
+	if err != nil {
+		return nil, fmt.Errorf("unable to template CNI manifest, %v", err)
+	}
+	return rendered.Bytes(), nil
+}
+
+// installCNI applies the CNI manifest and waits for a running CNI agent on every node
+func (m *MgmtCluster) installCNI(kubeconfig string, nodeCount int, timeout time.Duration) error {
+	plugin, manifest, err := getCNIPlugin(m.CNI)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderCNIManifest(manifest, m.KubernetesPodCidr, m.KubernetesServiceCidr)
+	if err != nil {
+		return err
+	}
+	err = writeToDisk(m.ClusterName, manifest.Name, rendered, 0644)
+	if err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	m.events <- Event{EventType: "progress", Event: "applying CNI manifest " + manifest.Name}
+	envs := map[string]string{
+		"KUBECONFIG": kubeconfig,
+	}
+	args := []string{
+		"apply",
+		"--filename=" + filepath.Join(home, ConfigDir, m.ClusterName, manifest.Name),
+	}
+	err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+	if err != nil {
+		return err
+	}
+
+	args = []string{
+		"get",
+		"pods",
+		"--namespace=" + plugin.namespace,
+		"--selector=" + plugin.selector,
+	}
+	return kubeRetry(envs, args, timeout, "Running", nodeCount, nil, m.events)
+}
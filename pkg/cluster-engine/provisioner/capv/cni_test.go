@@ -0,0 +1,113 @@
+package capv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderCNIManifests(t *testing.T) {
+	podCidr := "10.200.0.0/16"
+	for name, plugin := range cniPlugins {
+		for version := range plugin.manifests {
+			_, manifest, err := getCNIPlugin(CNI{Provider: name, Version: version})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			rendered, err := renderCNIManifest(manifest, podCidr, "")
+			if err != nil {
+				t.Fatalf("%v %v: %v", name, version, err)
+			}
+			if name != "antrea" && !strings.Contains(string(rendered), podCidr) {
+				t.Errorf("%v %v: expected pod cidr %v in manifest", name, version, podCidr)
+			}
+
+			decoder := yaml.NewDecoder(bytes.NewReader(rendered))
+			for {
+				var doc map[string]interface{}
+				err = decoder.Decode(&doc)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%v %v: invalid yaml, %v", name, version, err)
+				}
+				if doc["kind"] == nil || doc["apiVersion"] == nil {
+					t.Errorf("%v %v: document missing kind or apiVersion: %v", name, version, doc["metadata"])
+				}
+			}
+		}
+	}
+}
+
+func TestGetCNIPluginDefaults(t *testing.T) {
+	_, manifest, err := getCNIPlugin(CNI{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if manifest.Name != calicoManifest.Name {
+		t.Errorf("got %v, want %v", manifest.Name, calicoManifest.Name)
+	}
+
+	_, _, err = getCNIPlugin(CNI{Provider: "weave"})
+	if err == nil {
+		t.Error("expected an error for an unsupported CNI")
+	}
+	_, _, err = getCNIPlugin(CNI{Provider: "calico", Version: "v2.0"})
+	if err == nil {
+		t.Error("expected an error for an unsupported CNI version")
+	}
+}
+
+func TestRenderCiliumPodCidr(t *testing.T) {
+	podCidr := "10.200.0.0/16"
+	_, manifest, err := getCNIPlugin(CNI{Provider: "cilium", Version: "v1.7"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rendered, err := renderCNIManifest(manifest, podCidr, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var config map[string]string
+	decoder := yaml.NewDecoder(bytes.NewReader(rendered))
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+			Data map[string]string `yaml:"data"`
+		}
+		err = decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if doc.Kind == "ConfigMap" && doc.Metadata.Name == "cilium-config" {
+			config = doc.Data
+		}
+	}
+	if config == nil {
+		t.Fatal("expected a cilium-config ConfigMap")
+	}
+
+	// cilium v1.7 has no cluster-pool IPAM, the pod cidr has to come from the node podCIDRs
+	if config["native-routing-cidr"] != podCidr {
+		t.Errorf("got native-routing-cidr %q, want %q", config["native-routing-cidr"], podCidr)
+	}
+	if config["k8s-require-ipv4-pod-cidr"] != "true" {
+		t.Errorf("got k8s-require-ipv4-pod-cidr %q, want \"true\"", config["k8s-require-ipv4-pod-cidr"])
+	}
+	for key := range config {
+		if key == "ipam" || strings.HasPrefix(key, "cluster-pool-") {
+			t.Errorf("unexpected cilium v1.8 option %v in the v1.7 config", key)
+		}
+	}
+}
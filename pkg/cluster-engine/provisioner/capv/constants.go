@@ -5,5 +5,11 @@ const (
 	vsphereWorkloadFolder = "workloads"
 	vsphereBaseFolder     = "nks"
 	bootstrapKubeconfig   = "bootstrap.kubeconfig"
+	kindConfig            = "kind-config.yaml"
 	appName               = ".cluster-engine"
 )
+
+const (
+	bootstrapProviderKind     = "kind"
+	bootstrapProviderExisting = "existing"
+)
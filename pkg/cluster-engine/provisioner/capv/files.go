@@ -3,6 +3,7 @@ package capv
 import (
 	"archive/tar"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -16,99 +17,6 @@ type fileOnDisk struct {
 }
 
 var (
-	// elementStorageClass for installing trident
-	elementStorageClass = fileOnDisk{
-		Name: "solidfire-storage-class.yaml",
-		Contents: `apiVersion: storage.k8s.io/v1
-kind: StorageClass
-metadata:
-  name: solidfire-bronze
-provisioner: netapp.io/trident
-parameters:
-  backendType: "solidfire-san"
-  IOPS: "1500"
-  fsType: "ext4"
-  selector: "performance=bronze"
----
-apiVersion: storage.k8s.io/v1
-kind: StorageClass
-metadata:
-  name: solidfire-silver
-  annotations:
-    storageclass.kubernetes.io/is-default-class: "true"
-provisioner: netapp.io/trident
-parameters:
-  backendType: "solidfire-san"
-  IOPS: "5000"
-  fsType: "ext4"
-  selector: "performance=silver"
----
-apiVersion: storage.k8s.io/v1
-kind: StorageClass
-metadata:
-  name: solidfire-gold
-provisioner: netapp.io/trident
-parameters:
-  backendType: "solidfire-san"
-  IOPS: "7000"
-  fsType: "ext4"
-  selector: "performance=gold"`,
-	}
-	// elementBackendJSON for installing trident
-	elementBackendJSON = fileOnDisk{
-		Name: "backend-solidfire.json",
-		Contents: `{
-"version": 1,
-"storageDriverName": "solidfire-san",
-"Endpoint": "https://%s:%s@%s/json-rpc/8.0",
-"SVIP": "%s:3260",
-"TenantName": "%s",
-"Types": [{"Type": "Bronze", "Qos": {"minIOPS": 1000, "maxIOPS": 2000, "burstIOPS": 4000}},
-			{"Type": "Silver", "Qos": {"minIOPS": 4000, "maxIOPS": 6000, "burstIOPS": 8000}},
-			{"Type": "Gold", "Qos": {"minIOPS": 6000, "maxIOPS": 8000, "burstIOPS": 10000}}],
-"storage": [
-	{
-		"labels":{"performance":"gold", "cost":"4"},
-		"type":"Gold"
-	},
-	{
-		"labels":{"performance":"silver", "cost":"3"},
-		"type":"Silver"
-	},
-	{
-		"labels":{"performance":"bronze", "cost":"2"},
-		"type":"Bronze"
-	}
-]
-}`,
-	}
-	KustomizationFile = fileOnDisk{
-		Name: "kustomization.yaml",
-		Contents: `apiVersion: kustomize.config.k8s.io/v1beta1
-kind: Kustomization
-resources:
-- %[1]s-base.yaml
-patchesJson6902:
-- target:
-    group: infrastructure.cluster.x-k8s.io
-    version: v1alpha3
-    kind: VSphereMachineTemplate
-    name: %[1]s
-  path: patch1.yaml
-- target:
-    group: controlplane.cluster.x-k8s.io
-    version: v1alpha3
-    kind: KubeadmControlPlane
-    name: %[1]s
-  path: patch2.yaml
-- target:
-    group: bootstrap.cluster.x-k8s.io
-    version: v1alpha3
-    kind: KubeadmConfigTemplate
-    name: %[2]s
-  path: patch3.yaml
-`,
-	}
 	PatchFileOne = fileOnDisk{
 		Name: "patch1.yaml",
 		Contents: `- op: add
@@ -123,7 +31,7 @@ patchesJson6902:
   path: /spec/kubeadmConfigSpec/postKubeadmCommands
   value:
     - apt-get update
-    - apt-get install -y open-iscsi lsscsi sg3-utils multipath-tools scsitools
+    - apt-get install -y open-iscsi lsscsi sg3-utils multipath-tools scsitools nfs-common
     - echo "defaults {\n    user_friendly_names yes\n    find_multipaths yes\n}" > /etc/multipath.conf
     - systemctl enable multipath-tools.service
     - service multipath-tools restart
@@ -137,7 +45,7 @@ patchesJson6902:
   path: /spec/template/spec/postKubeadmCommands
   value:
     - apt-get update
-    - apt-get install -y open-iscsi lsscsi sg3-utils multipath-tools scsitools
+    - apt-get install -y open-iscsi lsscsi sg3-utils multipath-tools scsitools nfs-common
     - echo "defaults {\n    user_friendly_names yes\n    find_multipaths yes\n}" > /etc/multipath.conf
     - systemctl enable multipath-tools.service
     - service multipath-tools restart
@@ -184,8 +92,12 @@ func writeToDisk(dirname string, fileName string, specFile []byte, perms os.File
 func downloadFile(URL, fileName string, fileLocation string) error {
 	response, err := http.Get(URL)
 	if err != nil {
+		return err
 	}
 	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to download %v, %v", URL, response.Status)
+	}
 
 	fpath := filepath.Join(fileLocation, fileName)
 	file, err := os.Create(fpath)
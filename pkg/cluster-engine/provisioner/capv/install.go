@@ -12,6 +12,18 @@ import (
 // InstallControlPlane installs CAPv CRDs into the temporary bootstrap cluster
 func (m *MgmtCluster) InstallControlPlane() error {
 	var err error
+	err = m.validateCIDRs()
+	if err != nil {
+		return err
+	}
+	_, _, err = m.machineSizes()
+	if err != nil {
+		return err
+	}
+	_, err = m.vcenterThumbprint()
+	if err != nil {
+		return err
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return err
@@ -88,7 +100,7 @@ func (m *MgmtCluster) InstallControlPlane() error {
 		return fmt.Errorf("err: %v, stderr: %v, cmd: %v %v", err, string(stderr), c.CommandName, c.Args)
 	}
 
-	err = writeToDisk(m.ClusterName, m.ClusterName+"-base"+".yaml", []byte(stdout), 0644)
+	err = writeToDisk(m.ClusterName, fmt.Sprintf(baseSpec, m.ClusterName), []byte(stdout), 0644)
 	if err != nil {
 		return err
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/netapp/cake/pkg/cmds"
 
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	v3 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	capiv3 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
 )
@@ -68,6 +69,20 @@ func kubeGet(envs map[string]string, args []string, resource interface{}, ctx *c
 			return nil, fmt.Errorf("error with unmarshal: %v", err.Error())
 		}
 		return cMap, nil
+	case v1.NodeList:
+		var cMap v1.NodeList
+		err = json.Unmarshal(stdout, &cMap)
+		if err != nil {
+			return nil, fmt.Errorf("error with unmarshal: %v", err.Error())
+		}
+		return cMap, nil
+	case storagev1.StorageClassList:
+		var cMap storagev1.StorageClassList
+		err = json.Unmarshal(stdout, &cMap)
+		if err != nil {
+			return nil, fmt.Errorf("error with unmarshal: %v", err.Error())
+		}
+		return cMap, nil
 	case v3.HAProxyLoadBalancer:
 		var cList v1.List
 
@@ -0,0 +1,401 @@
+package capv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cmds"
+	"gopkg.in/yaml.v3"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	metallbAddonName       = "metallb"
+	metallbNamespace       = "metallb-system"
+	metallbConfig          = "metallb-config.yaml"
+	defaultMetallbManifest = "https://raw.githubusercontent.com/metallb/metallb/v0.9.3/manifests/metallb.yaml"
+)
+
+// metallbNamespaceManifest is manifests/namespace.yaml from the MetalLB release
+var metallbNamespaceManifest = fileOnDisk{
+	Name: "metallb-namespace.yaml",
+	Contents: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: metallb-system
+  labels:
+    app: metallb
+`,
+}
+
+// metallbConfigFile is the MetalLB v0.9 config carried in the metallb-system/config ConfigMap
+type metallbConfigFile struct {
+	AddressPools []metallbPool `yaml:"address-pools"`
+}
+
+type metallbPool struct {
+	Name      string   `yaml:"name"`
+	Protocol  string   `yaml:"protocol"`
+	Addresses []string `yaml:"addresses"`
+}
+
+// ipRange is an inclusive range of IPv4 addresses
+type ipRange struct {
+	first, last net.IP
+}
+
+func (r ipRange) contains(ip net.IP) bool {
+	ip = ip.To4()
+	return ip != nil && bytes.Compare(ip, r.first) >= 0 && bytes.Compare(ip, r.last) <= 0
+}
+
+func (r ipRange) overlaps(o ipRange) bool {
+	return bytes.Compare(r.first, o.last) <= 0 && bytes.Compare(o.first, r.last) <= 0
+}
+
+// parseAddressRange parses a MetalLB address, either a CIDR or a first-last range
+func parseAddressRange(address string) (ipRange, error) {
+	if strings.Contains(address, "/") {
+		return cidrRange(address)
+	}
+	parts := strings.Split(address, "-")
+	if len(parts) != 2 {
+		return ipRange{}, fmt.Errorf("invalid address range %v, must be a CIDR or first-last", address)
+	}
+	first := net.ParseIP(strings.TrimSpace(parts[0])).To4()
+	last := net.ParseIP(strings.TrimSpace(parts[1])).To4()
+	if first == nil || last == nil {
+		return ipRange{}, fmt.Errorf("invalid address range %v, only IPv4 addresses are supported", address)
+	}
+	if bytes.Compare(first, last) > 0 {
+		return ipRange{}, fmt.Errorf("invalid address range %v, first address is after the last", address)
+	}
+	return ipRange{first: first, last: last}, nil
+}
+
+func cidrRange(cidr string) (ipRange, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ipRange{}, err
+	}
+	first := network.IP.To4()
+	if first == nil {
+		return ipRange{}, fmt.Errorf("invalid CIDR %v, only IPv4 is supported", cidr)
+	}
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+	return ipRange{first: first, last: last}, nil
+}
+
+func init() {
+	RegisterAddon(metallbAddonName, newMetallbAddon)
+}
+
+// metallbAddon installs MetalLB in layer 2 mode to implement Services of type LoadBalancer
+type metallbAddon struct {
+	m *MgmtCluster
+}
+
+func newMetallbAddon(m *MgmtCluster) Addon {
+	return &metallbAddon{m: m}
+}
+
+func (l *metallbAddon) Name() string {
+	return metallbAddonName
+}
+
+func (l *metallbAddon) Enabled() bool {
+	return l.m.Addons.MetalLB.Enable
+}
+
+func (l *metallbAddon) DependsOn() []string {
+	return nil
+}
+
+func (l *metallbAddon) RequiredCommands() []string {
+	return []string{string(kubectl)}
+}
+
+// Validate checks the pool is on the workload network and clear of the cluster CIDRs
+func (l *metallbAddon) Validate() error {
+	m := l.m
+	if len(m.Addons.MetalLB.Addresses) == 0 {
+		return fmt.Errorf("at least one address range is required")
+	}
+	var pool []ipRange
+	for _, a := range m.Addons.MetalLB.Addresses {
+		r, err := parseAddressRange(a)
+		if err != nil {
+			return err
+		}
+		pool = append(pool, r)
+	}
+
+	if m.WorkloadNetworkCidr != "" {
+		workload, err := cidrRange(m.WorkloadNetworkCidr)
+		if err != nil {
+			return fmt.Errorf("invalid WorkloadNetworkCidr %v, %v", m.WorkloadNetworkCidr, err)
+		}
+		for i, r := range pool {
+			if !workload.contains(r.first) || !workload.contains(r.last) {
+				return fmt.Errorf("address range %v is not in the WorkloadNetworkCidr %v", m.Addons.MetalLB.Addresses[i], m.WorkloadNetworkCidr)
+			}
+		}
+	}
+
+	for name, cidr := range map[string]string{
+		"KubernetesPodCidr":     m.KubernetesPodCidr,
+		"KubernetesServiceCidr": m.KubernetesServiceCidr,
+	} {
+		if cidr == "" {
+			continue
+		}
+		c, err := cidrRange(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid %v %v, %v", name, cidr, err)
+		}
+		for i, r := range pool {
+			if r.overlaps(c) {
+				return fmt.Errorf("address range %v overlaps with %v %v", m.Addons.MetalLB.Addresses[i], name, cidr)
+			}
+		}
+	}
+	return nil
+}
+
+func (l *metallbAddon) Install() error {
+	m := l.m
+	m.events <- Event{EventType: "progress", Event: "installing the metallb addon"}
+	envs := m.permanentEnvs()
+
+	err := checkNodeAddresses(envs, m.Addons.MetalLB.Addresses)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := l.manifests()
+	if err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		m.events <- Event{EventType: "progress", Event: "applying " + manifest}
+		args := []string{
+			"apply",
+			"--filename=" + manifest,
+		}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = createMemberlistSecret(envs)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range []string{"deployment/controller", "daemonset/speaker"} {
+		m.events <- Event{EventType: "progress", Event: "waiting for " + w + " rollout"}
+		args := []string{
+			"rollout",
+			"status",
+			w,
+			"--namespace=" + metallbNamespace,
+			"--timeout=10m",
+		}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return fmt.Errorf("%v did not become ready, %v", w, err)
+		}
+	}
+	m.events <- Event{EventType: "progress", Event: "metallb addon install complete"}
+	return nil
+}
+
+// manifests writes the namespace and address pool config and returns the manifest locations in apply order
+func (l *metallbAddon) manifests() ([]string, error) {
+	m := l.m
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	err = writeToDisk(m.ClusterName, metallbNamespaceManifest.Name, []byte(metallbNamespaceManifest.Contents), 0644)
+	if err != nil {
+		return nil, err
+	}
+	config, err := metallbConfigMap(m.Addons.MetalLB.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	err = writeToDisk(m.ClusterName, metallbConfig, config, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	location := m.Addons.MetalLB.ManifestLocation
+	if location == "" {
+		location = defaultMetallbManifest
+	} else if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		location, err = homedir.Expand(location)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []string{
+		filepath.Join(home, ConfigDir, m.ClusterName, metallbNamespaceManifest.Name),
+		filepath.Join(home, ConfigDir, m.ClusterName, metallbConfig),
+		location,
+	}, nil
+}
+
+// metallbConfigMap renders the layer 2 address pool ConfigMap
+func metallbConfigMap(addresses []string) ([]byte, error) {
+	config, err := yaml.Marshal(metallbConfigFile{
+		AddressPools: []metallbPool{{Name: "default", Protocol: "layer2", Addresses: addresses}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	cm := v1.ConfigMap{
+		Data: map[string]string{"config": string(config)},
+	}
+	cm.APIVersion = "v1"
+	cm.Kind = "ConfigMap"
+	cm.Name = "config"
+	cm.Namespace = metallbNamespace
+	return json.MarshalIndent(cm, "", "  ")
+}
+
+// createMemberlistSecret creates the speaker memberlist key once, rotating it on reinstall would split the speakers
+func createMemberlistSecret(envs map[string]string) error {
+	args := []string{
+		"get",
+		"secret",
+		"memberlist",
+		"--namespace=" + metallbNamespace,
+		"--ignore-not-found",
+		"--output=name",
+	}
+	c := cmds.NewCommandLine(envs, string(kubectl), args, nil)
+	stdout, stderr, err := c.Program().Execute()
+	if err != nil || string(stderr) != "" {
+		return fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	}
+	if strings.TrimSpace(string(stdout)) != "" {
+		return nil
+	}
+
+	key := make([]byte, 128)
+	_, err = rand.Read(key)
+	if err != nil {
+		return err
+	}
+	args = []string{
+		"create",
+		"secret",
+		"generic",
+		"memberlist",
+		"--namespace=" + metallbNamespace,
+		"--from-literal=secretkey=" + base64.StdEncoding.EncodeToString(key),
+	}
+	return cmds.GenericExecute(envs, string(kubectl), args, nil)
+}
+
+// checkNodeAddresses errors if a node address is in the address pool
+func checkNodeAddresses(envs map[string]string, addresses []string) error {
+	args := []string{
+		"get",
+		"nodes",
+		`--output=jsonpath={range .items[*]}{.metadata.name}{"="}{range .status.addresses[*]}{.address}{","}{end}{"\n"}{end}`,
+	}
+	c := cmds.NewCommandLine(envs, string(kubectl), args, nil)
+	stdout, stderr, err := c.Program().Execute()
+	if err != nil || string(stderr) != "" {
+		return fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	}
+	return checkPoolAgainstNodes(addresses, string(stdout))
+}
+
+// checkPoolAgainstNodes checks the pool against lines of node=address,address,
+func checkPoolAgainstNodes(addresses []string, nodes string) error {
+	for _, a := range addresses {
+		r, err := parseAddressRange(a)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSpace(nodes), "\n") {
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			for _, address := range strings.Split(parts[1], ",") {
+				ip := net.ParseIP(address)
+				if ip != nil && r.contains(ip) {
+					return fmt.Errorf("address range %v contains the address %v of node %v", a, address, parts[0])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (l *metallbAddon) Uninstall() error {
+	l.m.events <- Event{EventType: "progress", Event: "uninstalling the metallb addon"}
+	manifests, err := l.manifests()
+	if err != nil {
+		return err
+	}
+	for i := len(manifests) - 1; i >= 0; i-- {
+		args := []string{
+			"delete",
+			"--ignore-not-found",
+			"--filename=" + manifests[i],
+		}
+		err = cmds.GenericExecute(l.m.permanentEnvs(), string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *metallbAddon) Status() (AddonStatus, error) {
+	var details []string
+	status := AddonStatus{Installed: true, Healthy: true}
+	for _, w := range []string{"deployment/controller", "daemonset/speaker"} {
+		args := []string{
+			"rollout",
+			"status",
+			w,
+			"--namespace=" + metallbNamespace,
+			"--timeout=5s",
+		}
+		stdout, stderr, err := runCommand(l.m.permanentEnvs(), string(kubectl), args)
+		if err != nil && strings.Contains(string(stderr), "(NotFound)") {
+			// the metallb-system namespace or the workload is missing
+			status.Installed = false
+			status.Healthy = false
+			details = append(details, w+": not installed")
+			continue
+		}
+		if err != nil {
+			status.Healthy = false
+			details = append(details, w+": "+strings.TrimSpace(string(stderr)))
+			continue
+		}
+		details = append(details, w+": "+strings.TrimSpace(string(stdout)))
+	}
+	status.Details = strings.Join(details, "\n")
+	return status, nil
+}
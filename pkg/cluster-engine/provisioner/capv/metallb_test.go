@@ -0,0 +1,130 @@
+package capv
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseAddressRange(t *testing.T) {
+	r, err := parseAddressRange("10.0.0.0/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.first.String() != "10.0.0.0" || r.last.String() != "10.0.0.3" {
+		t.Fatalf("unexpected range %v-%v", r.first, r.last)
+	}
+	r, err = parseAddressRange("10.0.0.10 - 10.0.0.20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.contains([]byte{10, 0, 0, 15}) || r.contains([]byte{10, 0, 0, 21}) {
+		t.Fatalf("unexpected range %v-%v", r.first, r.last)
+	}
+	for _, invalid := range []string{"10.0.0.20-10.0.0.10", "10.0.0.1", "fd00::1-fd00::2", "10.0.0.0/33"} {
+		_, err = parseAddressRange(invalid)
+		if err == nil {
+			t.Fatalf("%v: expected an error", invalid)
+		}
+	}
+}
+
+func TestMetallbValidate(t *testing.T) {
+	m := &MgmtCluster{}
+	m.Addons.MetalLB.Addresses = []string{"172.60.0.200-172.60.0.220"}
+	m.WorkloadNetworkCidr = "172.60.0.0/24"
+	m.KubernetesPodCidr = "192.168.0.0/16"
+	err := newMetallbAddon(m).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.WorkloadNetworkCidr = "172.61.0.0/24"
+	err = newMetallbAddon(m).Validate()
+	if err == nil {
+		t.Fatal("expected an error for a pool outside the workload network")
+	}
+
+	m.WorkloadNetworkCidr = ""
+	m.Addons.MetalLB.Addresses = []string{"192.168.10.0/28"}
+	err = newMetallbAddon(m).Validate()
+	if err == nil {
+		t.Fatal("expected an error for a pool overlapping the pod CIDR")
+	}
+}
+
+func TestCheckPoolAgainstNodes(t *testing.T) {
+	nodes := "node-1=172.60.0.10,node-1,\nnode-2=172.60.0.11,\n"
+	err := checkPoolAgainstNodes([]string{"172.60.0.200-172.60.0.220"}, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkPoolAgainstNodes([]string{"172.60.0.0/28"}, nodes)
+	if err == nil {
+		t.Fatal("expected an error for a pool containing a node address")
+	}
+}
+
+func TestMetallbConfigMap(t *testing.T) {
+	out, err := metallbConfigMap([]string{"172.60.0.200-172.60.0.220"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cm v1.ConfigMap
+	err = json.Unmarshal(out, &cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config metallbConfigFile
+	err = yaml.Unmarshal([]byte(cm.Data["config"]), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Namespace != metallbNamespace || len(config.AddressPools) != 1 || config.AddressPools[0].Protocol != "layer2" {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+}
+
+func TestMetallbStatus(t *testing.T) {
+	m := &MgmtCluster{}
+	f := &fakeRunner{outputs: map[string]fakeOutput{
+		"kubectl rollout": {stdout: "successfully rolled out\n"},
+	}}
+	defer withFakeRunner(t, m, f)()
+
+	status, err := newMetallbAddon(m).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Installed || !status.Healthy {
+		t.Fatalf("expected metallb installed and healthy, got %+v", status)
+	}
+
+	f.outputs["kubectl rollout"] = fakeOutput{
+		stderr: `Error from server (NotFound): namespaces "metallb-system" not found`,
+		err:    errors.New("exit status 1"),
+	}
+	status, err = newMetallbAddon(m).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Installed || status.Healthy || !strings.Contains(status.Details, "deployment/controller: not installed") {
+		t.Fatalf("expected metallb not installed, got %+v", status)
+	}
+
+	f.outputs["kubectl rollout"] = fakeOutput{
+		stderr: "error: timed out waiting for the condition",
+		err:    errors.New("exit status 1"),
+	}
+	status, err = newMetallbAddon(m).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Installed || status.Healthy {
+		t.Fatalf("expected metallb installed and unhealthy, got %+v", status)
+	}
+}
@@ -0,0 +1,380 @@
+package capv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cmds"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	observabilityAddonName = "observability"
+	observabilityDir       = "observability"
+	observabilityNamespace = "nks-system"
+	grafanaValues          = "grafana-values.yaml"
+	// grafanaArchiveValues is the copy of the archive grafana values pointed at the prometheus release
+	grafanaArchiveValues = "grafana-archive-values.yaml"
+	// archivePrometheusURL is the prometheus datasource in the grafana values shipped in the archive
+	archivePrometheusURL = "prometheus.nks-system.svc.cluster.local:8080"
+)
+
+// observabilityRelease is a helm release installed from a chart in the observability archive
+type observabilityRelease struct {
+	name  string
+	chart string
+	// workloads are waited on with `kubectl rollout status`
+	workloads []string
+}
+
+var observabilityReleases = []observabilityRelease{
+	{name: "prometheus", chart: "prometheus", workloads: []string{"deployment/prometheus-server"}},
+	{name: "loki", chart: "loki-stack", workloads: []string{"statefulset/loki", "daemonset/loki-promtail"}},
+	{name: "grafana", chart: "grafana", workloads: []string{"deployment/grafana"}},
+}
+
+// grafanaOverrides points grafana at the prometheus and loki releases and exposes it on a NodePort
+const grafanaOverrides = `service:
+  type: NodePort
+datasources:
+  datasources.yaml:
+    apiVersion: 1
+    datasources:
+    - name: Prometheus
+      type: prometheus
+      url: http://prometheus-server.%[1]s.svc.cluster.local
+      access: proxy
+      isDefault: true
+    - name: Loki
+      type: loki
+      url: http://loki.%[1]s.svc.cluster.local:3100
+      access: proxy
+`
+
+func init() {
+	RegisterAddon(observabilityAddonName, newObservabilityAddon)
+}
+
+// observabilityAddon installs prometheus, loki and grafana from the observability archive
+type observabilityAddon struct {
+	m *MgmtCluster
+}
+
+func newObservabilityAddon(m *MgmtCluster) Addon {
+	return &observabilityAddon{m: m}
+}
+
+func (o *observabilityAddon) Name() string {
+	return observabilityAddonName
+}
+
+func (o *observabilityAddon) Enabled() bool {
+	return o.m.Addons.Observability.Enable
+}
+
+// DependsOn storage, the prometheus and loki charts claim persistent volumes
+func (o *observabilityAddon) DependsOn() []string {
+	return []string{tridentAddonName, defaultStorageAddonName}
+}
+
+func (o *observabilityAddon) RequiredCommands() []string {
+	return []string{string(kubectl)}
+}
+
+func (o *observabilityAddon) Validate() error {
+	if o.m.Addons.Observability.ArchiveLocation == "" {
+		return fmt.Errorf("ArchiveLocation is required")
+	}
+	return nil
+}
+
+func (o *observabilityAddon) Install() error {
+	return installObservability(o.m)
+}
+
+func (o *observabilityAddon) Uninstall() error {
+	o.m.events <- Event{EventType: "progress", Event: "uninstalling the observability addon"}
+	installer := newChartInstaller(o.m.ClusterName, o.m.permanentEnvs())
+	for _, r := range observabilityReleases {
+		err := installer.Uninstall(r.name, o.m.observabilityNamespace())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *observabilityAddon) Status() (AddonStatus, error) {
+	var details []string
+	status := AddonStatus{Installed: true, Healthy: true}
+	installer := newChartInstaller(o.m.ClusterName, o.m.permanentEnvs())
+	for _, r := range observabilityReleases {
+		release, err := installer.Status(r.name, o.m.observabilityNamespace())
+		if err != nil {
+			return AddonStatus{}, err
+		}
+		if release == nil {
+			status.Installed = false
+			status.Healthy = false
+			details = append(details, "release "+r.name+": not installed")
+			continue
+		}
+		details = append(details, fmt.Sprintf("release %v: %v, revision %v, %v", r.name, release.ChartVersion, release.Revision, release.Status))
+		if release.Status != "deployed" {
+			status.Healthy = false
+			continue
+		}
+		for _, w := range r.workloads {
+			args := []string{
+				"rollout",
+				"status",
+				w,
+				"--namespace=" + o.m.observabilityNamespace(),
+				"--timeout=5s",
+			}
+			c := cmds.NewCommandLine(o.m.permanentEnvs(), string(kubectl), args, nil)
+			stdout, stderr, err := c.Program().Execute()
+			if err != nil {
+				status.Healthy = false
+				details = append(details, w+": "+strings.TrimSpace(string(stderr)))
+				continue
+			}
+			details = append(details, w+": "+strings.TrimSpace(string(stdout)))
+		}
+	}
+	status.Details = strings.Join(details, "\n")
+	return status, nil
+}
+
+// observabilityNamespace returns the namespace of the observability releases
+func (m *MgmtCluster) observabilityNamespace() string {
+	if m.Addons.Observability.Namespace == "" {
+		return observabilityNamespace
+	}
+	return m.Addons.Observability.Namespace
+}
+
+// observabilityCharts returns the directory holding the charts, extracting the archive if needed
+func (m *MgmtCluster) observabilityCharts() (string, error) {
+	location := m.Addons.Observability.ArchiveLocation
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		dir, err := m.observabilityDir()
+		if err != nil {
+			return "", err
+		}
+		m.events <- Event{EventType: "progress", Event: "extracting observability archive " + location}
+		_, err = extractRemoteArchive(location, dir)
+		if err != nil {
+			return "", fmt.Errorf("unable to extract observability archive, %v", err)
+		}
+		return dir, nil
+	}
+
+	location, err := homedir.Expand(location)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(location)
+	if err != nil {
+		return "", fmt.Errorf("unable to read observability archive, %v", err)
+	}
+	if info.IsDir() {
+		return location, nil
+	}
+	dir, err := m.observabilityDir()
+	if err != nil {
+		return "", err
+	}
+	m.events <- Event{EventType: "progress", Event: "extracting observability archive " + location}
+	_, err = extractLocalArchive(location, dir)
+	if err != nil {
+		return "", fmt.Errorf("unable to extract observability archive, %v", err)
+	}
+	return dir, nil
+}
+
+func (m *MgmtCluster) observabilityDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ConfigDir, m.ClusterName, observabilityDir)
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+func installObservability(m *MgmtCluster) error {
+	m.events <- Event{EventType: "progress", Event: "installing the observability addon"}
+	var err error
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	namespace := m.observabilityNamespace()
+	envs := m.permanentEnvs()
+	installer := newChartInstaller(m.ClusterName, envs)
+
+	dir, err := m.observabilityCharts()
+	if err != nil {
+		return err
+	}
+	charts, err := findCharts(dir)
+	if err != nil {
+		return err
+	}
+
+	err = writeToDisk(m.ClusterName, grafanaValues, []byte(fmt.Sprintf(grafanaOverrides, namespace)), 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range observabilityReleases {
+		chart, ok := charts[r.chart]
+		if !ok {
+			return fmt.Errorf("chart %v not found in observability archive", r.chart)
+		}
+		release := chartRelease{
+			Name:      r.name,
+			Namespace: namespace,
+			Chart:     chart,
+		}
+		if r.chart == "grafana" {
+			archiveValues, err := rewriteGrafanaValues(m.ClusterName, chart, namespace)
+			if err != nil {
+				return err
+			}
+			if archiveValues != "" {
+				release.ValuesFiles = append(release.ValuesFiles, archiveValues)
+			}
+			release.ValuesFiles = append(release.ValuesFiles, filepath.Join(home, ConfigDir, m.ClusterName, grafanaValues))
+		}
+		if location := m.Addons.Observability.ValuesFiles[r.name]; location != "" {
+			location, err = homedir.Expand(location)
+			if err != nil {
+				return err
+			}
+			release.ValuesFiles = append(release.ValuesFiles, location)
+		}
+		release.ValuesYAML = m.Addons.Observability.Values[r.name]
+		m.events <- Event{EventType: "progress", Event: "installing helm release " + r.name}
+		err = installer.Install(release)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range observabilityReleases {
+		for _, w := range r.workloads {
+			m.events <- Event{EventType: "progress", Event: "waiting for " + w + " rollout"}
+			args := []string{
+				"rollout",
+				"status",
+				w,
+				"--namespace=" + namespace,
+				"--timeout=10m",
+			}
+			err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+			if err != nil {
+				return fmt.Errorf("%v did not become ready, %v", w, err)
+			}
+		}
+	}
+
+	endpoint, err := grafanaEndpoint(envs, namespace)
+	if err != nil {
+		return err
+	}
+	m.events <- Event{EventType: "progress", Event: "grafana available at " + endpoint}
+	m.events <- Event{EventType: "progress", Event: "observability addon install complete"}
+	return err
+}
+
+// findCharts walks the extracted archive and returns chart directories keyed by chart name
+func findCharts(dir string) (map[string]string, error) {
+	charts := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != "Chart.yaml" {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var chart struct {
+			Name string `yaml:"name"`
+		}
+		err = yaml.Unmarshal(contents, &chart)
+		if err != nil {
+			return fmt.Errorf("unable to read %v, %v", path, err)
+		}
+		// subcharts are vendored under charts/, keep the outermost chart of a name
+		existing, ok := charts[chart.Name]
+		if chart.Name != "" && (!ok || strings.Count(path, string(filepath.Separator)) <= strings.Count(existing, string(filepath.Separator))) {
+			charts[chart.Name] = filepath.Dir(path)
+		}
+		return nil
+	})
+	return charts, err
+}
+
+// rewriteGrafanaValues writes a copy of the grafana values shipped next to the chart pointed at the prometheus
+// release to the cluster directory, the archive is left as is. It returns an empty location when the archive
+// has no grafana values.
+func rewriteGrafanaValues(clusterName, chart, namespace string) (string, error) {
+	location := filepath.Join(filepath.Dir(chart), grafanaValues)
+	contents, err := ioutil.ReadFile(location)
+	if os.IsNotExist(err) {
+		location = filepath.Join(chart, grafanaValues)
+		contents, err = ioutil.ReadFile(location)
+	}
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	rewritten := strings.ReplaceAll(string(contents), archivePrometheusURL, "prometheus-server."+namespace+".svc.cluster.local")
+	err = writeToDisk(clusterName, grafanaArchiveValues, []byte(rewritten), 0644)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ConfigDir, clusterName, grafanaArchiveValues), nil
+}
+
+// grafanaEndpoint returns the grafana NodePort URL on the first node
+func grafanaEndpoint(envs map[string]string, namespace string) (string, error) {
+	args := []string{
+		"get",
+		"service",
+		"grafana",
+		"--namespace=" + namespace,
+		"--output=jsonpath={.spec.ports[0].nodePort}",
+	}
+	c := cmds.NewCommandLine(envs, string(kubectl), args, nil)
+	port, stderr, err := c.Program().Execute()
+	if err != nil || string(stderr) != "" {
+		return "", fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	}
+	args = []string{
+		"get",
+		"nodes",
+		`--output=jsonpath={.items[0].status.addresses[?(@.type=="InternalIP")].address}`,
+	}
+	c = cmds.NewCommandLine(envs, string(kubectl), args, nil)
+	address, stderr, err := c.Program().Execute()
+	if err != nil || string(stderr) != "" {
+		return "", fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	}
+	return fmt.Sprintf("http://%v:%v", strings.TrimSpace(string(address)), strings.TrimSpace(string(port))), nil
+}
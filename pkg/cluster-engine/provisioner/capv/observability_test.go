@@ -0,0 +1,104 @@
+package capv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindCharts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "find_charts_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	charts := map[string]string{
+		"charts/prometheus":                   "name: prometheus\n",
+		"charts/loki-stack":                   "name: loki-stack\n",
+		"charts/loki-stack/charts/loki":       "name: loki\n",
+		"charts/grafana":                      "name: grafana\n",
+		"charts/loki-stack/charts/prometheus": "name: prometheus\n",
+	}
+	for d, contents := range charts {
+		err = os.MkdirAll(filepath.Join(dir, d), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, d, "Chart.yaml"), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := findCharts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range observabilityReleases {
+		if _, ok := found[r.chart]; !ok {
+			t.Fatalf("chart %v not found", r.chart)
+		}
+	}
+	if found["prometheus"] != filepath.Join(dir, "charts/prometheus") {
+		t.Fatalf("expected the top level prometheus chart, got %v", found["prometheus"])
+	}
+}
+
+func TestRewriteGrafanaValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grafana_values_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", filepath.Join(dir, "home"))
+
+	chart := filepath.Join(dir, "grafana")
+	location, err := rewriteGrafanaValues("test", chart, "monitoring")
+	if err != nil || location != "" {
+		t.Fatalf("expected no values, got %v, %v", location, err)
+	}
+
+	archiveValues := []byte("url: http://" + archivePrometheusURL + "\n")
+	err = ioutil.WriteFile(filepath.Join(dir, grafanaValues), archiveValues, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err = rewriteGrafanaValues("test", chart, "monitoring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location != filepath.Join(dir, "home", ConfigDir, "test", grafanaArchiveValues) {
+		t.Fatalf("expected the values in the cluster directory, got %v", location)
+	}
+	contents, err := ioutil.ReadFile(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), "http://prometheus-server.monitoring.svc.cluster.local\n") {
+		t.Fatalf("prometheus url not rewritten: %v", string(contents))
+	}
+	contents, err = ioutil.ReadFile(filepath.Join(dir, grafanaValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != string(archiveValues) {
+		t.Fatalf("archive values modified: %v", string(contents))
+	}
+}
+
+func TestObservabilityNamespace(t *testing.T) {
+	m := &MgmtCluster{}
+	m.Namespace = "default"
+	if got := m.observabilityNamespace(); got != observabilityNamespace {
+		t.Errorf("got %v, want %v", got, observabilityNamespace)
+	}
+	m.Addons.Observability.Namespace = "monitoring"
+	if got := m.observabilityNamespace(); got != "monitoring" {
+		t.Errorf("got %v, want monitoring", got)
+	}
+}
@@ -15,20 +15,14 @@ import (
 // CreatePermanent creates the permanent CAPv management cluster
 func (m *MgmtCluster) CreatePermanent() error {
 	var err error
-	var capiConfig string
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
 	kubeConfig := filepath.Join(home, ConfigDir, m.ClusterName, bootstrapKubeconfig)
-	if m.Addons.Solidfire.Enable {
-		err = injectTridentPrereqs(m.ClusterName, m.StorageNetwork, kubeConfig, nil)
-		if err != nil {
-			return err
-		}
-		capiConfig = filepath.Join(home, ConfigDir, m.ClusterName, m.ClusterName+"-final"+".yaml")
-	} else {
-		capiConfig = filepath.Join(home, ConfigDir, m.ClusterName, m.ClusterName+"-base"+".yaml")
+	capiConfig, err := m.renderSpec()
+	if err != nil {
+		return err
 	}
 
 	envs := map[string]string{
@@ -77,7 +71,6 @@ func (m *MgmtCluster) CreatePermanent() error {
 		return fmt.Errorf("get secret error: %v", err.Error())
 	}
 	workloadClusterKubeconfig := getKubeconfig.(v1.Secret).Data["value"]
-	m.Kubeconfig = string(workloadClusterKubeconfig)
 	err = writeToDisk(m.ClusterName, "kubeconfig", workloadClusterKubeconfig, 0644)
 	if err != nil {
 		return err
@@ -85,18 +78,14 @@ func (m *MgmtCluster) CreatePermanent() error {
 
 	// apply cni
 	permanentKubeconfig := filepath.Join(home, ConfigDir, m.ClusterName, "kubeconfig")
-	envs = map[string]string{
-		"KUBECONFIG": permanentKubeconfig,
-	}
-	args = []string{
-		"apply",
-		"--filename=https://docs.projectcalico.org/v3.12/manifests/calico.yaml",
-	}
-	err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+	err = m.installCNI(permanentKubeconfig, grepNum, timeout)
 	if err != nil {
 		return err
 	}
 
+	envs = map[string]string{
+		"KUBECONFIG": permanentKubeconfig,
+	}
 	args = []string{
 		"get",
 		"nodes",
@@ -91,5 +91,6 @@ func (m *MgmtCluster) PivotControlPlane() error {
 		return err
 	}
 	time.Sleep(5 * time.Second)
-	return err
+
+	return m.cleanupBootstrap()
 }
@@ -0,0 +1,380 @@
+package capv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cmds"
+)
+
+const (
+	rancherAddonName = "rancher"
+	rancherNamespace = "cattle-system"
+	rancherRepo      = "https://releases.rancher.com/server-charts/stable"
+	// rancherVersion is the newest rancher chart whose install docs use cert-manager v0.15.0
+	rancherVersion       = "2.4.5"
+	certManagerNamespace = "cert-manager"
+	certManagerRepo      = "https://charts.jetstack.io"
+	certManagerVersion   = "v0.15.0"
+	// certManagerCRDs is formatted with the cert-manager version
+	certManagerCRDs = "https://github.com/jetstack/cert-manager/releases/download/%s/cert-manager.crds.yaml"
+	defaultReplicas = 3
+)
+
+const (
+	rancherTLSRancher     = "rancher"
+	rancherTLSSecret      = "secret"
+	rancherTLSLetsEncrypt = "letsEncrypt"
+)
+
+func init() {
+	RegisterAddon(rancherAddonName, newRancherAddon)
+}
+
+// rancherAddon installs cert-manager and the Rancher server
+type rancherAddon struct {
+	m *MgmtCluster
+}
+
+func newRancherAddon(m *MgmtCluster) Addon {
+	return &rancherAddon{m: m}
+}
+
+func (r *rancherAddon) Name() string {
+	return rancherAddonName
+}
+
+func (r *rancherAddon) Enabled() bool {
+	return r.m.Addons.Rancher.Enable
+}
+
+// DependsOn metallb, when enabled, so the ingress can get a load balancer address
+func (r *rancherAddon) DependsOn() []string {
+	return []string{metallbAddonName}
+}
+
+func (r *rancherAddon) RequiredCommands() []string {
+	return []string{string(kubectl)}
+}
+
+func (r *rancherAddon) tlsSource() string {
+	if r.m.Addons.Rancher.TLSSource == "" {
+		return rancherTLSRancher
+	}
+	return r.m.Addons.Rancher.TLSSource
+}
+
+// needsCertManager is true unless the certificate is provided in a secret
+func (r *rancherAddon) needsCertManager() bool {
+	return r.tlsSource() != rancherTLSSecret
+}
+
+func (r *rancherAddon) Validate() error {
+	c := r.m.Addons.Rancher
+	if c.Hostname == "" {
+		return fmt.Errorf("Hostname is required")
+	}
+	if c.Replicas < 0 {
+		return fmt.Errorf("Replicas must not be negative")
+	}
+	switch r.tlsSource() {
+	case rancherTLSRancher:
+	case rancherTLSSecret:
+		if c.TLSCertificate == "" || c.TLSKey == "" {
+			return fmt.Errorf("TLSCertificate and TLSKey are required for the %v TLS source", rancherTLSSecret)
+		}
+	case rancherTLSLetsEncrypt:
+		if c.AirGap {
+			return fmt.Errorf("the %v TLS source is not available in an air gapped install", rancherTLSLetsEncrypt)
+		}
+		if c.LetsEncryptEmail == "" {
+			return fmt.Errorf("LetsEncryptEmail is required for the %v TLS source", rancherTLSLetsEncrypt)
+		}
+	default:
+		return fmt.Errorf("unknown TLS source: %v, must be one of: %v, %v, %v", c.TLSSource, rancherTLSRancher, rancherTLSSecret, rancherTLSLetsEncrypt)
+	}
+	if c.AirGap {
+		if c.RancherChart == "" {
+			return fmt.Errorf("RancherChart is required in an air gapped install")
+		}
+		if r.needsCertManager() && (c.CertManagerChart == "" || c.CertManagerCRDs == "") {
+			return fmt.Errorf("CertManagerChart and CertManagerCRDs are required in an air gapped install")
+		}
+	}
+	return nil
+}
+
+// releases returns the cert-manager release, if needed, and the rancher release
+func (r *rancherAddon) releases() ([]chartRelease, error) {
+	c := r.m.Addons.Rancher
+	var releases []chartRelease
+	if r.needsCertManager() {
+		release := chartRelease{
+			Name:      "cert-manager",
+			Namespace: certManagerNamespace,
+			Chart:     "cert-manager",
+			Repo:      certManagerRepo,
+			Version:   certManagerVersion,
+		}
+		if c.CertManagerChart != "" {
+			chart, err := homedir.Expand(c.CertManagerChart)
+			if err != nil {
+				return nil, err
+			}
+			release.Chart, release.Repo, release.Version = chart, "", ""
+		}
+		releases = append(releases, release)
+	}
+
+	replicas := c.Replicas
+	if replicas == 0 {
+		replicas = defaultReplicas
+	}
+	values := map[string]interface{}{
+		"hostname": c.Hostname,
+		"replicas": replicas,
+		"ingress": map[string]interface{}{
+			"tls": map[string]interface{}{"source": r.tlsSource()},
+		},
+	}
+	if r.tlsSource() == rancherTLSLetsEncrypt {
+		values["letsEncrypt"] = map[string]interface{}{"email": c.LetsEncryptEmail}
+	}
+	if c.CACertificate != "" {
+		values["privateCA"] = true
+	}
+	release := chartRelease{
+		Name:      "rancher",
+		Namespace: rancherNamespace,
+		Chart:     "rancher",
+		Repo:      rancherRepo,
+		Version:   c.Version,
+		Values:    values,
+	}
+	if release.Version == "" {
+		release.Version = rancherVersion
+	}
+	if c.RancherChart != "" {
+		chart, err := homedir.Expand(c.RancherChart)
+		if err != nil {
+			return nil, err
+		}
+		release.Chart, release.Repo, release.Version = chart, "", ""
+	}
+	return append(releases, release), nil
+}
+
+func (r *rancherAddon) Install() error {
+	m := r.m
+	c := m.Addons.Rancher
+	m.events <- Event{EventType: "progress", Event: "installing the rancher addon"}
+	envs := m.permanentEnvs()
+	installer := newChartInstaller(m.ClusterName, envs)
+	releases, err := r.releases()
+	if err != nil {
+		return err
+	}
+
+	if r.needsCertManager() {
+		crds := c.CertManagerCRDs
+		if crds == "" {
+			crds = fmt.Sprintf(certManagerCRDs, certManagerVersion)
+		} else if !strings.HasPrefix(crds, "http://") && !strings.HasPrefix(crds, "https://") {
+			crds, err = homedir.Expand(crds)
+			if err != nil {
+				return err
+			}
+		}
+		m.events <- Event{EventType: "progress", Event: "applying cert-manager CRDs " + crds}
+		args := []string{
+			"apply",
+			"--validate=false",
+			"--filename=" + crds,
+		}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, release := range releases {
+		if release.Name == "rancher" {
+			err = r.createTLSSecrets(envs)
+			if err != nil {
+				return err
+			}
+		}
+		m.events <- Event{EventType: "progress", Event: "installing helm release " + release.Name}
+		err = installer.Install(release)
+		if err != nil {
+			return err
+		}
+		for _, w := range rancherWorkloads(release.Name) {
+			m.events <- Event{EventType: "progress", Event: "waiting for " + w + " rollout"}
+			args := []string{
+				"rollout",
+				"status",
+				w,
+				"--namespace=" + release.Namespace,
+				"--timeout=15m",
+			}
+			err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+			if err != nil {
+				return fmt.Errorf("%v did not become ready, %v", w, err)
+			}
+		}
+	}
+
+	m.events <- Event{EventType: "progress", Event: "rancher available at https://" + c.Hostname}
+	status, err := installer.Status("rancher", rancherNamespace)
+	if err != nil {
+		return err
+	}
+	if status != nil && createsBootstrapSecret(status.ChartVersion) {
+		m.events <- Event{EventType: "progress", Event: fmt.Sprintf("rancher bootstrap password is in secret %v/bootstrap-secret, key bootstrapPassword", rancherNamespace)}
+	} else {
+		m.events <- Event{EventType: "progress", Event: "set the rancher admin password on first login"}
+	}
+	m.events <- Event{EventType: "progress", Event: "rancher addon install complete"}
+	return nil
+}
+
+// createsBootstrapSecret is true for rancher charts from 2.6 on, which generate the admin password into
+// the bootstrap-secret, earlier versions ask for it on first login. chartVersion is e.g. rancher-2.6.3.
+func createsBootstrapSecret(chartVersion string) bool {
+	version := strings.TrimPrefix(strings.TrimPrefix(chartVersion, "rancher-"), "v")
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return major > 2 || (major == 2 && minor >= 6)
+}
+
+func rancherWorkloads(release string) []string {
+	switch release {
+	case "cert-manager":
+		return []string{"deployment/cert-manager", "deployment/cert-manager-cainjector", "deployment/cert-manager-webhook"}
+	case "rancher":
+		return []string{"deployment/rancher"}
+	}
+	return nil
+}
+
+// createTLSSecrets creates the ingress certificate and private CA secrets Rancher expects
+func (r *rancherAddon) createTLSSecrets(envs map[string]string) error {
+	cfg := r.m.Addons.Rancher
+	var secrets [][]string
+	if r.tlsSource() == rancherTLSSecret {
+		cert, err := homedir.Expand(cfg.TLSCertificate)
+		if err != nil {
+			return err
+		}
+		key, err := homedir.Expand(cfg.TLSKey)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, []string{"tls", "tls-rancher-ingress", "--cert=" + cert, "--key=" + key})
+	}
+	if cfg.CACertificate != "" {
+		ca, err := homedir.Expand(cfg.CACertificate)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, []string{"generic", "tls-ca", "--from-file=cacerts.pem=" + ca})
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	args := []string{
+		"get",
+		"namespace",
+		rancherNamespace,
+	}
+	c := cmds.NewCommandLine(envs, string(kubectl), args, nil)
+	_, _, err := c.Program().Execute()
+	if err != nil {
+		args = []string{"create", "namespace", rancherNamespace}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, s := range secrets {
+		// recreate so a rerun picks up renewed certificates
+		args = []string{
+			"delete",
+			"secret",
+			s[1],
+			"--namespace=" + rancherNamespace,
+			"--ignore-not-found",
+		}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+		args = append([]string{"create", "secret", s[0], s[1], "--namespace=" + rancherNamespace}, s[2:]...)
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rancherAddon) Uninstall() error {
+	r.m.events <- Event{EventType: "progress", Event: "uninstalling the rancher addon"}
+	installer := newChartInstaller(r.m.ClusterName, r.m.permanentEnvs())
+	releases, err := r.releases()
+	if err != nil {
+		return err
+	}
+	for i := len(releases) - 1; i >= 0; i-- {
+		err = installer.Uninstall(releases[i].Name, releases[i].Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rancherAddon) Status() (AddonStatus, error) {
+	var details []string
+	status := AddonStatus{Installed: true, Healthy: true}
+	installer := newChartInstaller(r.m.ClusterName, r.m.permanentEnvs())
+	releases, err := r.releases()
+	if err != nil {
+		return AddonStatus{}, err
+	}
+	for _, release := range releases {
+		s, err := installer.Status(release.Name, release.Namespace)
+		if err != nil {
+			return AddonStatus{}, err
+		}
+		if s == nil {
+			status.Installed = false
+			status.Healthy = false
+			details = append(details, "release "+release.Name+": not installed")
+			continue
+		}
+		details = append(details, fmt.Sprintf("release %v: %v, revision %v, %v", release.Name, s.ChartVersion, s.Revision, s.Status))
+		if s.Status != "deployed" {
+			status.Healthy = false
+		}
+	}
+	if status.Installed {
+		details = append(details, "url: https://"+r.m.Addons.Rancher.Hostname)
+	}
+	status.Details = strings.Join(details, "\n")
+	return status, nil
+}
@@ -0,0 +1,76 @@
+package capv
+
+import (
+	"testing"
+)
+
+func TestRancherValidate(t *testing.T) {
+	tests := map[string]struct {
+		config Rancher
+		valid  bool
+	}{
+		"self signed":        {Rancher{Hostname: "rancher.local"}, true},
+		"no hostname":        {Rancher{}, false},
+		"unknown tls source": {Rancher{Hostname: "rancher.local", TLSSource: "vault"}, false},
+		"secret":             {Rancher{Hostname: "rancher.local", TLSSource: "secret", TLSCertificate: "tls.crt", TLSKey: "tls.key"}, true},
+		"secret no key":      {Rancher{Hostname: "rancher.local", TLSSource: "secret", TLSCertificate: "tls.crt"}, false},
+		"letsencrypt":        {Rancher{Hostname: "rancher.local", TLSSource: "letsEncrypt", LetsEncryptEmail: "a@b.c"}, true},
+		"letsencrypt airgap": {Rancher{Hostname: "rancher.local", TLSSource: "letsEncrypt", LetsEncryptEmail: "a@b.c", AirGap: true, RancherChart: "r", CertManagerChart: "c", CertManagerCRDs: "crds"}, false},
+		"airgap no charts":   {Rancher{Hostname: "rancher.local", AirGap: true}, false},
+		"airgap secret":      {Rancher{Hostname: "rancher.local", TLSSource: "secret", TLSCertificate: "tls.crt", TLSKey: "tls.key", AirGap: true, RancherChart: "r"}, true},
+	}
+	for name, test := range tests {
+		m := &MgmtCluster{}
+		m.Addons.Rancher = test.config
+		err := newRancherAddon(m).Validate()
+		if (err == nil) != test.valid {
+			t.Fatalf("%v: expected valid %v, got %v", name, test.valid, err)
+		}
+	}
+}
+
+func TestRancherReleases(t *testing.T) {
+	m := &MgmtCluster{}
+	m.Addons.Rancher = Rancher{Hostname: "rancher.local", TLSSource: "letsEncrypt", LetsEncryptEmail: "a@b.c"}
+	releases, err := newRancherAddon(m).(*rancherAddon).releases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 || releases[0].Name != "cert-manager" || releases[1].Name != "rancher" {
+		t.Fatalf("unexpected releases: %+v", releases)
+	}
+	if releases[1].Version != rancherVersion {
+		t.Fatalf("expected the default rancher version %v, got %v", rancherVersion, releases[1].Version)
+	}
+	values := releases[1].Values
+	if values["replicas"] != defaultReplicas || values["letsEncrypt"] == nil {
+		t.Fatalf("unexpected rancher values: %v", values)
+	}
+
+	m.Addons.Rancher = Rancher{Hostname: "rancher.local", TLSSource: "secret", RancherChart: "/charts/rancher"}
+	releases, err = newRancherAddon(m).(*rancherAddon).releases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 1 || releases[0].Chart != "/charts/rancher" || releases[0].Repo != "" {
+		t.Fatalf("unexpected releases: %+v", releases)
+	}
+}
+
+func TestCreatesBootstrapSecret(t *testing.T) {
+	tests := map[string]bool{
+		"rancher-2.4.5":    false,
+		"rancher-2.5.16":   false,
+		"rancher-2.6.3":    true,
+		"rancher-v2.7.0":   true,
+		"rancher-3.0.0":    true,
+		"rancher-2.6.0-rc": true,
+		"rancher":          false,
+		"":                 false,
+	}
+	for version, want := range tests {
+		if got := createsBootstrapSecret(version); got != want {
+			t.Errorf("%v: got %v, want %v", version, got, want)
+		}
+	}
+}
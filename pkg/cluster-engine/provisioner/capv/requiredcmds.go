@@ -9,7 +9,6 @@ const (
 	clusterctl requiredCmd = "clusterctl"
 	kubectl    requiredCmd = "kubectl"
 	docker     requiredCmd = "docker"
-	helm       requiredCmd = "helm"
 	tridentctl requiredCmd = "tridentctl"
 )
 
@@ -18,23 +17,22 @@ var RequiredCommands = cmds.ProvisionerCommands{Name: "required CAPV bootstrap c
 
 // RequiredCommands checks the PATH for required commands
 func (mc *MgmtCluster) RequiredCommands() []string {
-	kd := cmds.NewCommandLine(nil, string(kind), nil, nil)
-	RequiredCommands.AddCommand(kd.CommandName, kd)
-	c := cmds.NewCommandLine(nil, string(clusterctl), nil, nil)
-	RequiredCommands.AddCommand(c.CommandName, c)
-	k := cmds.NewCommandLine(nil, string(kubectl), nil, nil)
-	RequiredCommands.AddCommand(k.CommandName, k)
-	d := cmds.NewCommandLine(nil, string(docker), nil, nil)
-	RequiredCommands.AddCommand(d.CommandName, d)
-
-	if mc.Addons.Observability.Enable {
-		h := cmds.NewCommandLine(nil, string(helm), nil, nil)
-		RequiredCommands.AddCommand(h.CommandName, h)
+	names := []string{string(clusterctl), string(kubectl)}
+	if mc.Bootstrap.Provider != bootstrapProviderExisting {
+		names = append(names, string(kind), string(docker))
+	}
+	for _, a := range mc.enabledAddons() {
+		names = append(names, a.RequiredCommands()...)
 	}
 
-	if mc.Addons.Solidfire.Enable {
-		t := cmds.NewCommandLine(nil, string(tridentctl), nil, nil)
-		RequiredCommands.AddCommand(t.CommandName, t)
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		c := cmds.NewCommandLine(nil, name, nil, nil)
+		RequiredCommands.AddCommand(c.CommandName, c)
 	}
 
 	return RequiredCommands.Exist()
@@ -0,0 +1,191 @@
+package capv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/netapp/cake/pkg/config/types"
+	"gopkg.in/yaml.v3"
+)
+
+const workerTemplate = "%s-worker"
+
+// machineSizes parses the control plane and worker machine sizes
+func (m *MgmtCluster) machineSizes() (types.VMSize, types.VMSize, error) {
+	controlPlane, err := types.ParseVMSize(m.ControlPlaneMachineSize)
+	if err != nil {
+		return types.VMSize{}, types.VMSize{}, fmt.Errorf("invalid ControlPlaneMachineSize, %v", err)
+	}
+	worker, err := types.ParseVMSize(m.WorkerMachineSize)
+	if err != nil {
+		return types.VMSize{}, types.VMSize{}, fmt.Errorf("invalid WorkerMachineSize, %v", err)
+	}
+	return controlPlane, worker, nil
+}
+
+// sizePatches sizes the control plane and worker machines, it returns the patches and the names of
+// the VSphereMachineTemplates in the spec. The workers get their own template when the sizes differ.
+func (m *MgmtCluster) sizePatches() ([]kustomizePatch, []string, error) {
+	machineTemplates := []string{m.ClusterName}
+	controlPlane, worker, err := m.machineSizes()
+	if err != nil {
+		return nil, nil, err
+	}
+	if controlPlane.IsZero() && worker.IsZero() {
+		return nil, machineTemplates, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	spec, err := ioutil.ReadFile(filepath.Join(home, ConfigDir, m.ClusterName, fmt.Sprintf(baseSpec, m.ClusterName)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the base cluster spec, %v", err)
+	}
+	base, err := baseMachineTemplate(spec, m.ClusterName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if controlPlane.DiskGiB != 0 || worker.DiskGiB != 0 {
+		minimum, err := nodeTemplateDiskGiB(m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get the node template disk size, %v", err)
+		}
+		for name, size := range map[string]types.VMSize{"ControlPlaneMachineSize": controlPlane, "WorkerMachineSize": worker} {
+			err = validateMachineSize(minimum, size)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %v, %v", name, err)
+			}
+		}
+	}
+
+	var patches []kustomizePatch
+	if !controlPlane.IsZero() {
+		ops, err := yaml.Marshal(sizeOps(controlPlane))
+		if err != nil {
+			return nil, nil, err
+		}
+		patches = append(patches, kustomizePatch{
+			Target: machineTemplateTarget(m.ClusterName),
+			File:   fileOnDisk{Name: "patch-control-plane-size.yaml", Contents: string(ops)},
+		})
+	}
+	if controlPlane == worker {
+		return patches, machineTemplates, nil
+	}
+
+	workers, err := workerMachineTemplate(base, m.ClusterName, worker)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := fmt.Sprintf(workerTemplate, m.ClusterName)
+	ops, err := yaml.Marshal([]jsonPatchOp{{
+		Op:    "replace",
+		Path:  "/spec/template/spec/infrastructureRef/name",
+		Value: name,
+	}})
+	if err != nil {
+		return nil, nil, err
+	}
+	patches = append(patches,
+		kustomizePatch{Type: patchTypeResource, File: workers},
+		kustomizePatch{
+			Target: machineDeploymentTarget(m.ClusterName + "-md-0"),
+			File:   fileOnDisk{Name: "patch-worker-template.yaml", Contents: string(ops)},
+		},
+	)
+	return patches, append(machineTemplates, name), nil
+}
+
+// sizeOps sets the size fields that are not zero on a VSphereMachineTemplate
+func sizeOps(size types.VMSize) []jsonPatchOp {
+	var ops []jsonPatchOp
+	if size.CPUs != 0 {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/spec/template/spec/numCPUs", Value: size.CPUs})
+	}
+	if size.MemoryMiB != 0 {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/spec/template/spec/memoryMiB", Value: size.MemoryMiB})
+	}
+	if size.DiskGiB != 0 {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/spec/template/spec/diskGiB", Value: size.DiskGiB})
+	}
+	return ops
+}
+
+// baseMachineTemplate finds the cluster's VSphereMachineTemplate in the base cluster spec
+func baseMachineTemplate(spec []byte, clusterName string) (map[string]interface{}, error) {
+	d := yaml.NewDecoder(bytes.NewReader(spec))
+	for {
+		var obj map[string]interface{}
+		err := d.Decode(&obj)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the base cluster spec, %v", err)
+		}
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		if obj["kind"] == "VSphereMachineTemplate" && metadata["name"] == clusterName {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("VSphereMachineTemplate %v not found in the base cluster spec", clusterName)
+}
+
+// machineTemplateSpec returns spec.template.spec of a VSphereMachineTemplate
+func machineTemplateSpec(obj map[string]interface{}) (map[string]interface{}, error) {
+	spec, _ := obj["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	templateSpec, ok := template["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("VSphereMachineTemplate has no spec.template.spec")
+	}
+	return templateSpec, nil
+}
+
+// validateMachineSize checks the disk is not smaller than the disk of the node template, minimum is in GiB
+func validateMachineSize(minimum int, size types.VMSize) error {
+	if size.DiskGiB != 0 && int(size.DiskGiB) < minimum {
+		return fmt.Errorf("disk size %vGiB is smaller than the node template disk size %vGiB", size.DiskGiB, minimum)
+	}
+	return nil
+}
+
+// workerMachineTemplate copies the base machine template for the workers with the worker size
+func workerMachineTemplate(base map[string]interface{}, clusterName string, size types.VMSize) (fileOnDisk, error) {
+	b, err := yaml.Marshal(base)
+	if err != nil {
+		return fileOnDisk{}, err
+	}
+	var workers map[string]interface{}
+	err = yaml.Unmarshal(b, &workers)
+	if err != nil {
+		return fileOnDisk{}, err
+	}
+
+	workers["metadata"].(map[string]interface{})["name"] = fmt.Sprintf(workerTemplate, clusterName)
+	spec, err := machineTemplateSpec(workers)
+	if err != nil {
+		return fileOnDisk{}, err
+	}
+	if size.CPUs != 0 {
+		spec["numCPUs"] = size.CPUs
+	}
+	if size.MemoryMiB != 0 {
+		spec["memoryMiB"] = size.MemoryMiB
+	}
+	if size.DiskGiB != 0 {
+		spec["diskGiB"] = size.DiskGiB
+	}
+
+	contents, err := yaml.Marshal(workers)
+	if err != nil {
+		return fileOnDisk{}, err
+	}
+	return fileOnDisk{Name: "worker-machine-template.yaml", Contents: string(contents)}, nil
+}
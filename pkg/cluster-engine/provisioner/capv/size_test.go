@@ -0,0 +1,125 @@
+package capv
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/netapp/cake/pkg/config/types"
+	"gopkg.in/yaml.v3"
+)
+
+const sizeBaseSpec = `apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Cluster
+metadata:
+  name: test
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1alpha3
+kind: VSphereMachineTemplate
+metadata:
+  name: test
+  namespace: default
+spec:
+  template:
+    spec:
+      cloneMode: linkedClone
+      diskGiB: 25
+      memoryMiB: 8192
+      numCPUs: 2
+      template: ubuntu-1804-kube-v1.17.3
+`
+
+// withBaseSpec writes the base cluster spec and fakes a node template with a 40GiB disk
+func withBaseSpec(t *testing.T, m *MgmtCluster) func() {
+	diskGiB := nodeTemplateDiskGiB
+	nodeTemplateDiskGiB = func(*MgmtCluster) (int, error) { return 40, nil }
+	home := os.Getenv("HOME")
+	dir, err := ioutil.TempDir("", "size_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("HOME", dir)
+	err = writeToDisk(m.ClusterName, "test-base.yaml", []byte(sizeBaseSpec), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		nodeTemplateDiskGiB = diskGiB
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSizePatchesSameSize(t *testing.T) {
+	m := &MgmtCluster{}
+	m.ClusterName = "test"
+	m.ControlPlaneMachineSize = "medium"
+	m.WorkerMachineSize = "cpus=4,memoryMiB=8192,diskGiB=50"
+	defer withBaseSpec(t, m)()
+
+	patches, templates, err := m.sizePatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 || len(templates) != 1 {
+		t.Fatalf("expected one shared machine template patch, got %+v, %v", patches, templates)
+	}
+	var ops []jsonPatchOp
+	err = yaml.Unmarshal([]byte(patches[0].File.Contents), &ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 || patches[0].Target.Kind != "VSphereMachineTemplate" {
+		t.Fatalf("unexpected patch: %+v", patches[0])
+	}
+}
+
+func TestSizePatchesWorkerTemplate(t *testing.T) {
+	m := &MgmtCluster{}
+	m.ClusterName = "test"
+	m.WorkerMachineSize = "large"
+	defer withBaseSpec(t, m)()
+
+	patches, templates, err := m.sizePatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 2 || templates[1] != "test-worker" {
+		t.Fatalf("expected a worker machine template, got %v", templates)
+	}
+	if len(patches) != 2 || patches[0].Type != patchTypeResource || patches[1].Target.Kind != "MachineDeployment" {
+		t.Fatalf("unexpected patches: %+v", patches)
+	}
+	if !strings.Contains(patches[0].File.Contents, "name: test-worker") || !strings.Contains(patches[0].File.Contents, "numCPUs: 8") {
+		t.Fatalf("unexpected worker template: %v", patches[0].File.Contents)
+	}
+}
+
+func TestSizePatchesDiskTooSmall(t *testing.T) {
+	m := &MgmtCluster{}
+	m.ClusterName = "test"
+	// larger than the diskGiB of the base spec, but smaller than the node template disk
+	m.ControlPlaneMachineSize = "diskGiB=30"
+	defer withBaseSpec(t, m)()
+
+	_, _, err := m.sizePatches()
+	if err == nil || !strings.Contains(err.Error(), "node template disk size 40GiB") {
+		t.Fatalf("expected a disk smaller than the node template to fail, got %v", err)
+	}
+}
+
+func TestWorkerMachineTemplateKeepsBase(t *testing.T) {
+	base, err := baseMachineTemplate([]byte(sizeBaseSpec), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = workerMachineTemplate(base, "test", types.VMSize{CPUs: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, _ := machineTemplateSpec(base)
+	if spec["numCPUs"] != 2 {
+		t.Fatalf("base template was modified: %v", spec)
+	}
+}
@@ -0,0 +1,173 @@
+package capv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	elementDriver        = "solidfire-san"
+	elementBackend       = "backend-solidfire.json"
+	elementClasses       = "solidfire-storage-class.yaml"
+	defaultElementAPI    = "8.0"
+	defaultSVIPPort      = 3260
+	defaultElementFSType = "ext4"
+)
+
+// defaultSolidfireTiers are used when no tiers are configured
+var defaultSolidfireTiers = []SolidfireTier{
+	{Name: "Bronze", MinIOPS: 1000, MaxIOPS: 2000, BurstIOPS: 4000, Labels: map[string]string{"cost": "2"}},
+	{Name: "Silver", MinIOPS: 4000, MaxIOPS: 6000, BurstIOPS: 8000, Labels: map[string]string{"cost": "3"}, Default: true},
+	{Name: "Gold", MinIOPS: 6000, MaxIOPS: 8000, BurstIOPS: 10000, Labels: map[string]string{"cost": "4"}},
+}
+
+// elementBackendConfig is the Trident backend config for the solidfire-san driver
+type elementBackendConfig struct {
+	Version           int           `json:"version"`
+	StorageDriverName string        `json:"storageDriverName"`
+	BackendName       string        `json:"backendName"`
+	Endpoint          string        `json:"Endpoint"`
+	SVIP              string        `json:"SVIP"`
+	TenantName        string        `json:"TenantName"`
+	Types             []elementType `json:"Types"`
+	Storage           []elementPool `json:"storage"`
+}
+
+type elementType struct {
+	Type string     `json:"Type"`
+	Qos  elementQos `json:"Qos"`
+}
+
+type elementQos struct {
+	MinIOPS   int64 `json:"minIOPS"`
+	MaxIOPS   int64 `json:"maxIOPS"`
+	BurstIOPS int64 `json:"burstIOPS"`
+}
+
+// elementPool is a virtual storage pool the StorageClasses select by label
+type elementPool struct {
+	Labels map[string]string `json:"labels"`
+	Type   string            `json:"type"`
+}
+
+func (s Solidfire) tiers() []SolidfireTier {
+	if len(s.Tiers) == 0 {
+		return defaultSolidfireTiers
+	}
+	return s.Tiers
+}
+
+// tierLabels adds the performance label every tier is selected by
+func tierLabels(t SolidfireTier) map[string]string {
+	labels := map[string]string{"performance": strings.ToLower(t.Name)}
+	for k, v := range t.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// tierSelector is the Trident selector matching all of the tier labels
+func tierSelector(labels map[string]string) string {
+	var keys []string
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	selector := make([]string, len(keys))
+	for i, k := range keys {
+		selector[i] = k + "=" + labels[k]
+	}
+	return strings.Join(selector, "; ")
+}
+
+func validateSolidfireTiers(tiers []SolidfireTier) error {
+	names := map[string]bool{}
+	defaults := 0
+	for i, t := range tiers {
+		if t.Name == "" {
+			return fmt.Errorf("tier %v: Name is required", i)
+		}
+		if names[strings.ToLower(t.Name)] {
+			return fmt.Errorf("tier %v: duplicate tier name", t.Name)
+		}
+		names[strings.ToLower(t.Name)] = true
+		if t.MinIOPS <= 0 || t.MinIOPS > t.MaxIOPS || t.MaxIOPS > t.BurstIOPS {
+			return fmt.Errorf("tier %v: IOPS must satisfy 0 < MinIOPS <= MaxIOPS <= BurstIOPS", t.Name)
+		}
+		if t.Default {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return fmt.Errorf("only one default tier is allowed")
+	}
+	return nil
+}
+
+// elementBackendFiles generates the backend JSON and a StorageClass per tier for the Element backend
+func elementBackendFiles(clusterName string, s Solidfire) (fileOnDisk, fileOnDisk, error) {
+	apiVersion := s.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultElementAPI
+	}
+	port := s.SVIPPort
+	if port == 0 {
+		port = defaultSVIPPort
+	}
+
+	config := elementBackendConfig{
+		Version:           1,
+		StorageDriverName: elementDriver,
+		BackendName:       elementDriver,
+		Endpoint:          fmt.Sprintf("https://%s:%s@%s/json-rpc/%s", s.User, s.Password, s.MVIP, apiVersion),
+		SVIP:              fmt.Sprintf("%s:%d", s.SVIP, port),
+		TenantName:        clusterName,
+	}
+	var docs []string
+	for _, t := range s.tiers() {
+		labels := tierLabels(t)
+		config.Types = append(config.Types, elementType{
+			Type: t.Name,
+			Qos:  elementQos{MinIOPS: t.MinIOPS, MaxIOPS: t.MaxIOPS, BurstIOPS: t.BurstIOPS},
+		})
+		config.Storage = append(config.Storage, elementPool{Labels: labels, Type: t.Name})
+
+		fsType := t.FSType
+		if fsType == "" {
+			fsType = defaultElementFSType
+		}
+		sc := storagev1.StorageClass{
+			TypeMeta:    metav1.TypeMeta{APIVersion: "storage.k8s.io/v1", Kind: "StorageClass"},
+			ObjectMeta:  metav1.ObjectMeta{Name: "solidfire-" + strings.ToLower(t.Name), Labels: map[string]string{tridentClassLabel: "true"}},
+			Provisioner: "netapp.io/trident",
+			Parameters: map[string]string{
+				"backendType": elementDriver,
+				"IOPS":        fmt.Sprintf("%d", (t.MinIOPS+t.MaxIOPS)/2),
+				"fsType":      fsType,
+				"selector":    tierSelector(labels),
+			},
+		}
+		if t.Default {
+			sc.Annotations = map[string]string{defaultClassAnnotation: "true"}
+		}
+		doc, err := yaml.Marshal(sc)
+		if err != nil {
+			return fileOnDisk{}, fileOnDisk{}, err
+		}
+		docs = append(docs, string(doc))
+	}
+
+	backend, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fileOnDisk{}, fileOnDisk{}, err
+	}
+	return fileOnDisk{Name: elementBackend, Contents: string(backend)},
+		fileOnDisk{Name: elementClasses, Contents: strings.Join(docs, "---\n")},
+		nil
+}
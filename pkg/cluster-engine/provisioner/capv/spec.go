@@ -0,0 +1,316 @@
+package capv
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cmds"
+	"github.com/netapp/cake/pkg/config/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	baseSpec  = "%s-base.yaml"
+	finalSpec = "%s-final.yaml"
+	userPatch = "patch-user-%d.yaml"
+)
+
+const (
+	patchTypeJSON6902  = "json6902"
+	patchTypeStrategic = "strategic"
+	// patchTypeResource adds File as a new object rather than patching one
+	patchTypeResource = "resource"
+)
+
+// kustomization is the kustomize.config.k8s.io/v1beta1 Kustomization
+type kustomization struct {
+	APIVersion            string               `yaml:"apiVersion"`
+	Kind                  string               `yaml:"kind"`
+	Resources             []string             `yaml:"resources"`
+	PatchesStrategicMerge []string             `yaml:"patchesStrategicMerge,omitempty"`
+	PatchesJSON6902       []kustomizationPatch `yaml:"patchesJson6902,omitempty"`
+}
+
+type kustomizationPatch struct {
+	Target kustomizeTarget `yaml:"target"`
+	Path   string          `yaml:"path"`
+}
+
+// kustomizeTarget selects an object in the base cluster spec
+type kustomizeTarget struct {
+	Group   string `yaml:"group"`
+	Version string `yaml:"version"`
+	Kind    string `yaml:"kind"`
+	Name    string `yaml:"name"`
+}
+
+// kustomizePatch is a JSON6902 or strategic merge patch against an object in the base cluster spec,
+// strategic merge patches find their target from the object in the patch so Target is ignored
+type kustomizePatch struct {
+	Type   string
+	Target kustomizeTarget
+	File   fileOnDisk
+}
+
+// jsonPatchOp is a single JSON6902 operation
+type jsonPatchOp struct {
+	Op    string      `yaml:"op"`
+	Path  string      `yaml:"path"`
+	Value interface{} `yaml:"value,omitempty"`
+}
+
+func clusterTarget(name string) kustomizeTarget {
+	return kustomizeTarget{Group: "cluster.x-k8s.io", Version: "v1alpha3", Kind: "Cluster", Name: name}
+}
+
+func vsphereClusterTarget(name string) kustomizeTarget {
+	return kustomizeTarget{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3", Kind: "VSphereCluster", Name: name}
+}
+
+func machineDeploymentTarget(name string) kustomizeTarget {
+	return kustomizeTarget{Group: "cluster.x-k8s.io", Version: "v1alpha3", Kind: "MachineDeployment", Name: name}
+}
+
+func machineTemplateTarget(name string) kustomizeTarget {
+	return kustomizeTarget{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3", Kind: "VSphereMachineTemplate", Name: name}
+}
+
+func controlPlaneTarget(name string) kustomizeTarget {
+	return kustomizeTarget{Group: "controlplane.cluster.x-k8s.io", Version: "v1alpha3", Kind: "KubeadmControlPlane", Name: name}
+}
+
+func configTemplateTarget(name string) kustomizeTarget {
+	return kustomizeTarget{Group: "bootstrap.cluster.x-k8s.io", Version: "v1alpha3", Kind: "KubeadmConfigTemplate", Name: name}
+}
+
+// renderClusterSpec runs a `kubectl kustomize` command to apply the patches to the base cluster spec
+func renderClusterSpec(clusterName string, patches []kustomizePatch, kubeconfigLocation string, ctx *context.Context) error {
+	var err error
+	var envs map[string]string
+
+	k := kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  []string{fmt.Sprintf(baseSpec, clusterName)},
+	}
+	for _, p := range patches {
+		err = writeToDisk(clusterName, p.File.Name, []byte(p.File.Contents), 0644)
+		if err != nil {
+			return err
+		}
+		switch p.Type {
+		case patchTypeStrategic:
+			k.PatchesStrategicMerge = append(k.PatchesStrategicMerge, p.File.Name)
+			continue
+		case patchTypeResource:
+			k.Resources = append(k.Resources, p.File.Name)
+			continue
+		}
+		k.PatchesJSON6902 = append(k.PatchesJSON6902, kustomizationPatch{Target: p.Target, Path: p.File.Name})
+	}
+	kf, err := yaml.Marshal(k)
+	if err != nil {
+		return err
+	}
+	err = writeToDisk(clusterName, "kustomization.yaml", kf, 0644)
+	if err != nil {
+		return err
+	}
+
+	if kubeconfigLocation != "" {
+		envs = map[string]string{"KUBECONFIG": kubeconfigLocation}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	loc := filepath.Join(home, ConfigDir, clusterName)
+	args := []string{"kustomize", loc}
+
+	c := cmds.NewCommandLine(envs, string(kubectl), args, ctx)
+
+	stdout, stderr, err := c.Program().Execute()
+	if err != nil || string(stderr) != "" {
+		return fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	}
+	return writeToDisk(clusterName, fmt.Sprintf(finalSpec, clusterName), stdout, 0644)
+}
+
+// validateCIDRs checks the cluster CIDRs do not overlap each other or the vSphere networks
+func (m *MgmtCluster) validateCIDRs() error {
+	return types.ValidateCIDRs(map[string]string{
+		"KubernetesPodCidr":     m.KubernetesPodCidr,
+		"KubernetesServiceCidr": m.KubernetesServiceCidr,
+		"ManagementNetworkCidr": m.ManagementNetworkCidr,
+		"WorkloadNetworkCidr":   m.WorkloadNetworkCidr,
+		"StorageNetworkCidr":    m.StorageNetworkCidr,
+	})
+}
+
+// cidrPatches sets the pod and service CIDRs on the Cluster and KubeadmControlPlane
+func cidrPatches(clusterName, podCidr, serviceCidr string) ([]kustomizePatch, error) {
+	var clusterOps []jsonPatchOp
+	networking := map[string]string{}
+	if podCidr != "" {
+		clusterOps = append(clusterOps, jsonPatchOp{
+			Op:    "add",
+			Path:  "/spec/clusterNetwork/pods",
+			Value: map[string][]string{"cidrBlocks": {podCidr}},
+		})
+		networking["podSubnet"] = podCidr
+	}
+	if serviceCidr != "" {
+		clusterOps = append(clusterOps, jsonPatchOp{
+			Op:    "add",
+			Path:  "/spec/clusterNetwork/services",
+			Value: map[string][]string{"cidrBlocks": {serviceCidr}},
+		})
+		networking["serviceSubnet"] = serviceCidr
+	}
+	if len(clusterOps) == 0 {
+		return nil, nil
+	}
+	controlPlaneOps := []jsonPatchOp{{
+		Op:    "add",
+		Path:  "/spec/kubeadmConfigSpec/clusterConfiguration/networking",
+		Value: networking,
+	}}
+
+	clusterPatch, err := yaml.Marshal(clusterOps)
+	if err != nil {
+		return nil, err
+	}
+	controlPlanePatch, err := yaml.Marshal(controlPlaneOps)
+	if err != nil {
+		return nil, err
+	}
+	return []kustomizePatch{
+		{
+			Target: clusterTarget(clusterName),
+			File:   fileOnDisk{Name: "patch-cluster-network.yaml", Contents: string(clusterPatch)},
+		},
+		{
+			Target: controlPlaneTarget(clusterName),
+			File:   fileOnDisk{Name: "patch-kubeadm-networking.yaml", Contents: string(controlPlanePatch)},
+		},
+	}, nil
+}
+
+// userPatches reads the user supplied patches from the config
+func userPatches(patches []Patch) ([]kustomizePatch, error) {
+	var result []kustomizePatch
+	for i, p := range patches {
+		if (p.Patch == "") == (p.Path == "") {
+			return nil, fmt.Errorf("patch %v: exactly one of Patch or Path is required", i)
+		}
+		contents := p.Patch
+		if p.Path != "" {
+			location, err := homedir.Expand(p.Path)
+			if err != nil {
+				return nil, err
+			}
+			b, err := ioutil.ReadFile(location)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read patch %v, %v", i, err)
+			}
+			contents = string(b)
+		}
+
+		kp := kustomizePatch{
+			File: fileOnDisk{Name: fmt.Sprintf(userPatch, i), Contents: contents},
+		}
+		switch p.Type {
+		case patchTypeStrategic:
+			kp.Type = patchTypeStrategic
+		case patchTypeJSON6902:
+			if p.Target.Kind == "" || p.Target.Name == "" {
+				return nil, fmt.Errorf("patch %v: a json6902 patch requires a target Kind and Name", i)
+			}
+			kp.Type = patchTypeJSON6902
+			kp.Target = kustomizeTarget{
+				Group:   p.Target.Group,
+				Version: p.Target.Version,
+				Kind:    p.Target.Kind,
+				Name:    p.Target.Name,
+			}
+		default:
+			return nil, fmt.Errorf("patch %v: unknown patch type: %v, must be %v or %v", i, p.Type, patchTypeStrategic, patchTypeJSON6902)
+		}
+		result = append(result, kp)
+	}
+	return result, nil
+}
+
+// specPatches composes the built-in and user patches, user patches are applied last
+func (m *MgmtCluster) specPatches() ([]kustomizePatch, error) {
+	patches, err := cidrPatches(m.ClusterName, m.KubernetesPodCidr, m.KubernetesServiceCidr)
+	if err != nil {
+		return nil, err
+	}
+	size, machineTemplates, err := m.sizePatches()
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, size...)
+	trust, err := m.trustPatches()
+	if err != nil {
+		return nil, err
+	}
+	patches = append(patches, trust...)
+	if newTridentAddon(m).Enabled() {
+		patches = append(patches, tridentPrereqPatches(m.ClusterName, m.StorageNetwork, machineTemplates)...)
+	}
+	user, err := userPatches(m.Patches)
+	if err != nil {
+		return nil, err
+	}
+	return append(patches, user...), nil
+}
+
+// renderSpec renders the cluster spec and returns its location
+func (m *MgmtCluster) renderSpec() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	patches, err := m.specPatches()
+	if err != nil {
+		return "", err
+	}
+	if len(patches) == 0 {
+		return filepath.Join(home, ConfigDir, m.ClusterName, fmt.Sprintf(baseSpec, m.ClusterName)), nil
+	}
+	kubeConfig := filepath.Join(home, ConfigDir, m.ClusterName, bootstrapKubeconfig)
+	err = renderClusterSpec(m.ClusterName, patches, kubeConfig, nil)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ConfigDir, m.ClusterName, fmt.Sprintf(finalSpec, m.ClusterName)), nil
+}
+
+// RenderSpec renders the cluster spec that CreatePermanent applies, without applying it
+func (m *MgmtCluster) RenderSpec() ([]byte, error) {
+	location, err := m.renderSpec()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(location)
+}
+
+// DryRun renders the cluster spec and then deletes the bootstrap cluster, so the next deploy can create it again
+func (m *MgmtCluster) DryRun() ([]byte, error) {
+	spec, err := m.RenderSpec()
+	derr := m.DeleteBootstrap()
+	if err != nil {
+		return nil, err
+	}
+	if derr != nil {
+		return nil, fmt.Errorf("unable to delete the bootstrap cluster, %v", derr)
+	}
+	return spec, nil
+}
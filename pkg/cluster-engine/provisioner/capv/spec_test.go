@@ -0,0 +1,133 @@
+package capv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCIDRPatches(t *testing.T) {
+	patches, err := cidrPatches("test", "172.16.0.0/16", "10.100.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("expected 2 patches, got %v", len(patches))
+	}
+	var ops []jsonPatchOp
+	err = yaml.Unmarshal([]byte(patches[0].File.Contents), &ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || ops[0].Path != "/spec/clusterNetwork/pods" || ops[1].Path != "/spec/clusterNetwork/services" {
+		t.Fatalf("unexpected cluster network patch: %v", patches[0].File.Contents)
+	}
+	if patches[1].Target.Kind != "KubeadmControlPlane" {
+		t.Fatalf("expected KubeadmControlPlane target, got %v", patches[1].Target.Kind)
+	}
+}
+
+func TestCIDRPatchesEmpty(t *testing.T) {
+	patches, err := cidrPatches("test", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 0 {
+		t.Fatalf("expected no patches, got %v", len(patches))
+	}
+}
+
+func TestUserPatches(t *testing.T) {
+	patches, err := userPatches([]Patch{
+		{Type: "strategic", Patch: "kind: VSphereMachineTemplate"},
+		{Type: "json6902", Target: PatchTarget{Kind: "Cluster", Name: "test"}, Patch: "[]"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("expected 2 patches, got %v", len(patches))
+	}
+	if patches[0].Type != patchTypeStrategic || patches[1].Target.Kind != "Cluster" {
+		t.Fatalf("unexpected patches: %+v", patches)
+	}
+	if patches[0].File.Name == patches[1].File.Name {
+		t.Fatalf("expected unique patch file names, got %v", patches[0].File.Name)
+	}
+}
+
+func TestUserPatchesInvalid(t *testing.T) {
+	tests := map[string]Patch{
+		"no contents":    {Type: "strategic"},
+		"both contents":  {Type: "strategic", Patch: "a", Path: "b"},
+		"unknown type":   {Type: "merge", Patch: "a"},
+		"missing target": {Type: "json6902", Patch: "[]"},
+	}
+	for name, p := range tests {
+		_, err := userPatches([]Patch{p})
+		if err == nil {
+			t.Fatalf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider string
+		deleted  string
+	}{
+		{name: "kind", provider: bootstrapProviderKind, deleted: "kind delete cluster --name=test"},
+		{name: "existing", provider: bootstrapProviderExisting, deleted: "clusterctl delete --all --include-crd --include-namespace"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := &MgmtCluster{events: make(chan interface{}, 10)}
+			m.ClusterName = "test"
+			m.Bootstrap.Provider = c.provider
+			f := &fakeRunner{outputs: map[string]fakeOutput{"kind delete": {}, "clusterctl delete": {}}}
+			defer withFakeRunner(t, m, f)()
+			defer withBaseSpec(t, m)()
+
+			spec, err := m.DryRun()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(spec), "kind: VSphereMachineTemplate") {
+				t.Errorf("expected the rendered spec, got:\n%s", spec)
+			}
+			if len(f.ran) != 1 || f.ran[0] != c.deleted {
+				t.Errorf("expected the bootstrap cluster to be deleted with %v, ran %v", c.deleted, f.ran)
+			}
+		})
+	}
+}
+
+func TestDryRunRenderFails(t *testing.T) {
+	m := &MgmtCluster{events: make(chan interface{}, 10)}
+	m.ClusterName = "test"
+	f := &fakeRunner{outputs: map[string]fakeOutput{"kind delete": {}}}
+	defer withFakeRunner(t, m, f)()
+
+	// without a base spec on disk rendering fails, the bootstrap cluster is still deleted
+	if _, err := m.DryRun(); err == nil {
+		t.Fatal("expected an error without a base spec")
+	}
+	if len(f.ran) != 1 || f.ran[0] != "kind delete cluster --name=test" {
+		t.Errorf("expected the kind bootstrap cluster to be deleted, ran %v", f.ran)
+	}
+}
+
+func TestDryRunDeleteFails(t *testing.T) {
+	m := &MgmtCluster{events: make(chan interface{}, 10)}
+	m.ClusterName = "test"
+	f := &fakeRunner{outputs: map[string]fakeOutput{"kind delete": {err: errors.New("exit status 1")}}}
+	defer withFakeRunner(t, m, f)()
+	defer withBaseSpec(t, m)()
+
+	if _, err := m.DryRun(); err == nil || !strings.Contains(err.Error(), "unable to delete the bootstrap cluster") {
+		t.Fatalf("expected the delete error, got %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+package capv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cmds"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+const (
+	defaultStorageAddonName = "default-storage"
+	defaultClassAnnotation  = "storageclass.kubernetes.io/is-default-class"
+	// betaDefaultClassAnnotation is still honored by the apiserver
+	betaDefaultClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+	defaultLonghornManifest    = "https://raw.githubusercontent.com/longhorn/longhorn/v1.0.0/deploy/longhorn.yaml"
+	// vsphereCSIConfigDir is where the vsphere-config-secret is mounted in the CSI pods
+	vsphereCSIConfigDir = "/etc/cloud"
+)
+
+const (
+	storageProviderLocalPath  = "local-path"
+	storageProviderLonghorn   = "longhorn"
+	storageProviderVsphereCSI = "vsphere-csi"
+)
+
+func init() {
+	RegisterAddon(defaultStorageAddonName, newDefaultStorageAddon)
+}
+
+// defaultStorageAddon installs a provisioner and marks it the default StorageClass when the cluster has none
+type defaultStorageAddon struct {
+	m *MgmtCluster
+}
+
+func newDefaultStorageAddon(m *MgmtCluster) Addon {
+	return &defaultStorageAddon{m: m}
+}
+
+func (d *defaultStorageAddon) Name() string {
+	return defaultStorageAddonName
+}
+
+func (d *defaultStorageAddon) Enabled() bool {
+	return d.m.Addons.DefaultStorage.Enable
+}
+
+// DependsOn trident, which creates its own default StorageClass
+func (d *defaultStorageAddon) DependsOn() []string {
+	return []string{tridentAddonName}
+}
+
+func (d *defaultStorageAddon) RequiredCommands() []string {
+	return []string{string(kubectl)}
+}
+
+func (d *defaultStorageAddon) Validate() error {
+	switch d.provider() {
+	case storageProviderLocalPath, storageProviderLonghorn, storageProviderVsphereCSI:
+		return nil
+	}
+	return fmt.Errorf("unknown storage provider: %v, must be one of: %v, %v, %v", d.m.Addons.DefaultStorage.Provider, storageProviderLocalPath, storageProviderLonghorn, storageProviderVsphereCSI)
+}
+
+func (d *defaultStorageAddon) provider() string {
+	if d.m.Addons.DefaultStorage.Provider == "" {
+		return storageProviderLocalPath
+	}
+	return strings.ToLower(d.m.Addons.DefaultStorage.Provider)
+}
+
+func (d *defaultStorageAddon) Install() error {
+	m := d.m
+	m.events <- Event{EventType: "progress", Event: "installing the default storage addon"}
+	envs := m.permanentEnvs()
+
+	existing, err := defaultStorageClass(envs)
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		m.events <- Event{EventType: "progress", Event: "default StorageClass " + existing + " found, skipping default storage addon"}
+		return nil
+	}
+
+	if d.provider() == storageProviderVsphereCSI {
+		err = checkVsphereProviderIDs(envs)
+		if err != nil {
+			return err
+		}
+	}
+
+	manifests, err := d.manifests()
+	if err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		m.events <- Event{EventType: "progress", Event: "applying " + manifest}
+		args := []string{
+			"apply",
+			"--filename=" + manifest,
+		}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	var className string
+	var workloads []string
+	namespace := ""
+	switch d.provider() {
+	case storageProviderLocalPath:
+		className = "local-path"
+		namespace = "local-path-storage"
+		workloads = []string{"deployment/local-path-provisioner"}
+	case storageProviderLonghorn:
+		className = "longhorn"
+		namespace = "longhorn-system"
+		workloads = []string{"daemonset/longhorn-manager", "deployment/longhorn-driver-deployer"}
+	case storageProviderVsphereCSI:
+		className = "vsphere-csi"
+		namespace = "kube-system"
+		workloads = []string{"deployment/vsphere-csi-controller", "daemonset/vsphere-csi-node"}
+	}
+	for _, w := range workloads {
+		m.events <- Event{EventType: "progress", Event: "waiting for " + w + " rollout"}
+		args := []string{
+			"rollout",
+			"status",
+			w,
+			"--namespace=" + namespace,
+			"--timeout=" + (10 * time.Minute).String(),
+		}
+		err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+		if err != nil {
+			return fmt.Errorf("%v did not become ready, %v", w, err)
+		}
+	}
+
+	m.events <- Event{EventType: "progress", Event: "marking StorageClass " + className + " as the default"}
+	args := []string{
+		"patch",
+		"storageclass",
+		className,
+		"--patch={\"metadata\": {\"annotations\":{\"" + defaultClassAnnotation + "\":\"true\"}}}",
+	}
+	err = cmds.GenericExecute(envs, string(kubectl), args, nil)
+	if err != nil {
+		return err
+	}
+	m.events <- Event{EventType: "progress", Event: "default storage addon install complete"}
+	return nil
+}
+
+// manifests writes the manifests for the provider and returns their locations
+func (d *defaultStorageAddon) manifests() ([]string, error) {
+	m := d.m
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	var files []fileOnDisk
+	var locations []string
+	switch d.provider() {
+	case storageProviderLocalPath:
+		files = []fileOnDisk{localPathManifest}
+	case storageProviderLonghorn:
+		location := m.Addons.DefaultStorage.ManifestLocation
+		if location == "" {
+			location = defaultLonghornManifest
+		} else if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			location, err = homedir.Expand(location)
+			if err != nil {
+				return nil, err
+			}
+		}
+		locations = append(locations, location)
+		files = []fileOnDisk{longhornStorageClass}
+	case storageProviderVsphereCSI:
+		// the secret holds the vCenter password, so it is only readable by the user
+		secret, err := m.vsphereCSISecret()
+		if err != nil {
+			return nil, err
+		}
+		err = writeToDisk(m.ClusterName, vsphereCSISecret.Name, secret, 0600)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, filepath.Join(home, ConfigDir, m.ClusterName, vsphereCSISecret.Name))
+		files = []fileOnDisk{vsphereCSIManifest, m.vsphereStorageClass()}
+	}
+	for _, f := range files {
+		err = writeToDisk(m.ClusterName, f.Name, []byte(f.Contents), 0644)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, filepath.Join(home, ConfigDir, m.ClusterName, f.Name))
+	}
+	return locations, nil
+}
+
+func (d *defaultStorageAddon) Uninstall() error {
+	d.m.events <- Event{EventType: "progress", Event: "uninstalling the default storage addon"}
+	manifests, err := d.manifests()
+	if err != nil {
+		return err
+	}
+	// delete in reverse so StorageClasses go before their provisioner
+	for i := len(manifests) - 1; i >= 0; i-- {
+		args := []string{
+			"delete",
+			"--ignore-not-found",
+			"--filename=" + manifests[i],
+		}
+		err = cmds.GenericExecute(d.m.permanentEnvs(), string(kubectl), args, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *defaultStorageAddon) Status() (AddonStatus, error) {
+	existing, err := defaultStorageClass(d.m.permanentEnvs())
+	if err != nil {
+		return AddonStatus{}, err
+	}
+	if existing == "" {
+		return AddonStatus{Details: "no default StorageClass"}, nil
+	}
+	return AddonStatus{Installed: true, Healthy: true, Details: "default StorageClass: " + existing}, nil
+}
+
+// vsphereStorageClass returns the vsphere-csi StorageClass, without a StoragePolicyName the datastore
+// is picked by the driver instead of by a storage policy
+func (m *MgmtCluster) vsphereStorageClass() fileOnDisk {
+	sc := vsphereStorageClass
+	if m.Addons.DefaultStorage.StoragePolicyName != "" {
+		sc.Contents += fmt.Sprintf("parameters:\n  storagepolicyname: %q\n", m.Addons.DefaultStorage.StoragePolicyName)
+	}
+	return sc
+}
+
+// vsphereCSISecret renders the vsphere-config-secret with the csi-vsphere.conf for the vCenter in the config
+func (m *MgmtCluster) vsphereCSISecret() ([]byte, error) {
+	thumbprint, err := m.vcenterThumbprint()
+	if err != nil {
+		return nil, err
+	}
+	server := m.VcenterServer
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid VcenterServer %v, %v", m.VcenterServer, err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	var ca []byte
+	if m.VcenterCABundle != "" {
+		location, err := homedir.Expand(m.VcenterCABundle)
+		if err != nil {
+			return nil, err
+		}
+		ca, err = ioutil.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read VcenterCABundle, %v", err)
+		}
+	}
+
+	conf := []string{
+		"[Global]",
+		"cluster-id = " + gcfgQuote(m.ClusterName),
+		"",
+		"[VirtualCenter " + gcfgQuote(u.Hostname()) + "]",
+		"user = " + gcfgQuote(m.VsphereUsername),
+		"password = " + gcfgQuote(m.VspherePassword),
+		"port = " + gcfgQuote(port),
+		"datacenters = " + gcfgQuote(m.Datacenter),
+		fmt.Sprintf("insecure-flag = \"%v\"", m.VcenterInsecure),
+	}
+	if thumbprint != "" {
+		conf = append(conf, "thumbprint = "+gcfgQuote(thumbprint))
+	}
+	if ca != nil {
+		conf = append(conf, "ca-file = "+gcfgQuote(vsphereCSIConfigDir+"/vcenter-ca.pem"))
+	}
+
+	secret := vsphereCSISecret.Contents + "  csi-vsphere.conf: |\n" + indent(strings.Join(conf, "\n")+"\n", "    ")
+	if ca != nil {
+		secret += "  vcenter-ca.pem: |\n" + indent(string(ca), "    ")
+	}
+	return []byte(secret), nil
+}
+
+// gcfgQuote quotes a csi-vsphere.conf value
+func gcfgQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// indent prefixes every line of text, text ends with a newline
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// checkVsphereProviderIDs checks the vSphere cloud provider initialized every node, the CSI
+// driver finds the VM of a node by its providerID
+func checkVsphereProviderIDs(envs map[string]string) error {
+	args := []string{
+		"get",
+		"nodes",
+		"--output=json",
+	}
+	result, err := kubeGet(envs, args, v1.NodeList{}, nil)
+	if err != nil {
+		return err
+	}
+	missing := nodesWithoutProviderID(result.(v1.NodeList))
+	if len(missing) > 0 {
+		return fmt.Errorf("nodes %v have no vSphere providerID, the vSphere cloud provider has to initialize them before the CSI driver is installed", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// nodesWithoutProviderID returns the nodes without a vsphere:// providerID
+func nodesWithoutProviderID(nodes v1.NodeList) []string {
+	var missing []string
+	for _, n := range nodes.Items {
+		if !strings.HasPrefix(n.Spec.ProviderID, "vsphere://") {
+			missing = append(missing, n.Name)
+		}
+	}
+	return missing
+}
+
+// defaultStorageClass returns the name of the default StorageClass, or an empty string if there is none
+func defaultStorageClass(envs map[string]string) (string, error) {
+	args := []string{
+		"get",
+		"storageclass",
+		"--output=json",
+	}
+	result, err := kubeGet(envs, args, storagev1.StorageClassList{}, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, sc := range result.(storagev1.StorageClassList).Items {
+		if isDefaultStorageClass(sc) {
+			return sc.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func isDefaultStorageClass(sc storagev1.StorageClass) bool {
+	return sc.Annotations[defaultClassAnnotation] == "true" || sc.Annotations[betaDefaultClassAnnotation] == "true"
+}
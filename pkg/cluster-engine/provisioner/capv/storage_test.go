@@ -0,0 +1,165 @@
+package capv
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsDefaultStorageClass(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		"default":      {map[string]string{defaultClassAnnotation: "true"}, true},
+		"beta default": {map[string]string{betaDefaultClassAnnotation: "true"}, true},
+		"not default":  {map[string]string{defaultClassAnnotation: "false"}, false},
+		"none":         {nil, false},
+	}
+	for name, test := range tests {
+		sc := storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+		if isDefaultStorageClass(sc) != test.expected {
+			t.Fatalf("%v: expected %v", name, test.expected)
+		}
+	}
+}
+
+func TestDefaultStorageValidate(t *testing.T) {
+	for provider, valid := range map[string]bool{
+		"":            true,
+		"local-path":  true,
+		"Longhorn":    true,
+		"vsphere-csi": true,
+		"ceph":        false,
+	} {
+		m := &MgmtCluster{}
+		m.Addons.DefaultStorage.Provider = provider
+		err := newDefaultStorageAddon(m).Validate()
+		if (err == nil) != valid {
+			t.Fatalf("provider %q: expected valid %v, got %v", provider, valid, err)
+		}
+	}
+}
+
+func TestVsphereStorageClass(t *testing.T) {
+	m := &MgmtCluster{}
+	var sc storagev1.StorageClass
+	if err := yaml.Unmarshal([]byte(m.vsphereStorageClass().Contents), &sc); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(m.vsphereStorageClass().Contents, "parameters") {
+		t.Errorf("expected no parameters without a storage policy:\n%s", m.vsphereStorageClass().Contents)
+	}
+
+	m.Addons.DefaultStorage.StoragePolicyName = "vSAN Default Storage Policy"
+	var parsed struct {
+		Parameters map[string]string `yaml:"parameters"`
+	}
+	if err := yaml.Unmarshal([]byte(m.vsphereStorageClass().Contents), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Parameters["storagepolicyname"] != "vSAN Default Storage Policy" {
+		t.Errorf("got storagepolicyname %q", parsed.Parameters["storagepolicyname"])
+	}
+}
+
+func TestVsphereCSIManifest(t *testing.T) {
+	var kinds []string
+	decoder := yaml.NewDecoder(strings.NewReader(vsphereCSIManifest.Contents))
+	for {
+		var doc struct {
+			Kind string `yaml:"kind"`
+		}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, doc.Kind)
+	}
+	expected := "CSIDriver,ServiceAccount,ClusterRole,ClusterRoleBinding,Deployment,DaemonSet"
+	if strings.Join(kinds, ",") != expected {
+		t.Errorf("got %v, want %v", strings.Join(kinds, ","), expected)
+	}
+}
+
+func TestVsphereCSISecret(t *testing.T) {
+	ca, err := ioutil.TempFile("", "ca_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(ca.Name())
+	ca.WriteString("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")
+	ca.Close()
+
+	m := &MgmtCluster{}
+	m.ClusterName = "test"
+	m.VcenterServer = "https://vcenter.local:8443/sdk"
+	m.VsphereUsername = "administrator@vsphere.local"
+	m.VspherePassword = `pa"ss`
+	m.Datacenter = "DC0"
+	m.VcenterThumbprint = "f1:12:f4:a5:ef:2c:ae:5e:3b:33:17:5e:42:cc:2f:ee:90:30:6e:7a"
+	m.VcenterCABundle = ca.Name()
+
+	contents, err := m.vsphereCSISecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secret v1.Secret
+	if err := yaml.NewDecoder(bytes.NewReader(contents)).Decode(&struct {
+		StringData *map[string]string `yaml:"stringData"`
+	}{&secret.StringData}); err != nil {
+		t.Fatal(err)
+	}
+	conf := secret.StringData["csi-vsphere.conf"]
+	for _, e := range []string{
+		`cluster-id = "test"`,
+		`[VirtualCenter "vcenter.local"]`,
+		`port = "8443"`,
+		`password = "pa\"ss"`,
+		`datacenters = "DC0"`,
+		`insecure-flag = "false"`,
+		`thumbprint = "F1:12:F4:A5:EF:2C:AE:5E:3B:33:17:5E:42:CC:2F:EE:90:30:6E:7A"`,
+		`ca-file = "/etc/cloud/vcenter-ca.pem"`,
+	} {
+		if !strings.Contains(conf, e) {
+			t.Errorf("expected %q in csi-vsphere.conf:\n%s", e, conf)
+		}
+	}
+	if !strings.Contains(secret.StringData["vcenter-ca.pem"], "BEGIN CERTIFICATE") {
+		t.Errorf("expected the CA bundle in the secret, got %q", secret.StringData["vcenter-ca.pem"])
+	}
+
+	m.VcenterThumbprint = ""
+	m.VcenterCABundle = ""
+	m.VcenterInsecure = true
+	contents, err = m.vsphereCSISecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), `insecure-flag = "true"`) || strings.Contains(string(contents), "vcenter-ca.pem") {
+		t.Errorf("expected an insecure config without a CA:\n%s", contents)
+	}
+}
+
+func TestNodesWithoutProviderID(t *testing.T) {
+	nodes := v1.NodeList{Items: []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "cp-0"}, Spec: v1.NodeSpec{ProviderID: "vsphere://4201"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}, Spec: v1.NodeSpec{ProviderID: "aws:///i-1"}},
+	}}
+	missing := nodesWithoutProviderID(nodes)
+	if strings.Join(missing, ",") != "worker-0,worker-1" {
+		t.Errorf("got %v, want worker-0,worker-1", missing)
+	}
+}
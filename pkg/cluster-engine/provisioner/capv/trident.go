@@ -0,0 +1,476 @@
+package capv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/netapp/cake/pkg/cmds"
+
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	tridentAddonName = "trident"
+	tridentNamespace = "trident"
+	tridentBackend   = "backend-%s.json"
+	tridentClasses   = "storage-classes-%s.yaml"
+	// tridentClassLabel marks the StorageClasses the addon creates, so uninstall finds them by label
+	tridentClassLabel = "cluster-engine.netapp.io/trident"
+	// tridentInstallerURL is formatted with the version twice
+	tridentInstallerURL = "https://github.com/NetApp/trident/releases/download/v%s/trident-installer-%s.tar.gz"
+)
+
+const (
+	ontapNAS        = "ontap-nas"
+	ontapNASEconomy = "ontap-nas-economy"
+	ontapSAN        = "ontap-san"
+)
+
+// ontapBackend is the Trident backend config for the ONTAP drivers
+type ontapBackend struct {
+	Version           int    `json:"version"`
+	StorageDriverName string `json:"storageDriverName"`
+	BackendName       string `json:"backendName"`
+	ManagementLIF     string `json:"managementLIF"`
+	DataLIF           string `json:"dataLIF,omitempty"`
+	SVM               string `json:"svm"`
+	Username          string `json:"username"`
+	Password          string `json:"password"`
+}
+
+// tridentVersion is the output of `tridentctl version --output=json`
+type tridentVersion struct {
+	Server struct {
+		Version string `json:"version"`
+	} `json:"server"`
+}
+
+// tridentBackendList is the output of `tridentctl get backend --output=json`
+type tridentBackendList struct {
+	Items []struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+	} `json:"items"`
+}
+
+func init() {
+	RegisterAddon(tridentAddonName, newTridentAddon)
+}
+
+// tridentAddon installs Trident and the Element backend
+type tridentAddon struct {
+	m *MgmtCluster
+}
+
+func newTridentAddon(m *MgmtCluster) Addon {
+	return &tridentAddon{m: m}
+}
+
+func (t *tridentAddon) Name() string {
+	return tridentAddonName
+}
+
+func (t *tridentAddon) Enabled() bool {
+	return t.m.Addons.Solidfire.Enable || t.m.Addons.Trident.Enable
+}
+
+func (t *tridentAddon) DependsOn() []string {
+	return nil
+}
+
+// RequiredCommands includes tridentctl unless a version is pinned, which uses tridentctl from the installer
+func (t *tridentAddon) RequiredCommands() []string {
+	if t.m.Addons.Trident.Version != "" {
+		return []string{string(kubectl)}
+	}
+	return []string{string(tridentctl), string(kubectl)}
+}
+
+func (t *tridentAddon) Validate() error {
+	s := t.m.Addons.Solidfire
+	defaults := 0
+	if s.Enable {
+		if s.MVIP == "" || s.SVIP == "" || s.User == "" || s.Password == "" {
+			return fmt.Errorf("Solidfire MVIP, SVIP, User and Password are required")
+		}
+		err := validateSolidfireTiers(s.tiers())
+		if err != nil {
+			return err
+		}
+		for _, tier := range s.tiers() {
+			if tier.Default {
+				defaults++
+			}
+		}
+	}
+	if !t.m.Addons.Trident.Enable {
+		return nil
+	}
+	names := map[string]bool{}
+	for i, b := range t.m.Addons.Trident.Backends {
+		if b.Name == "" {
+			return fmt.Errorf("backend %v: Name is required", i)
+		}
+		if names[b.Name] {
+			return fmt.Errorf("backend %v: duplicate backend name", b.Name)
+		}
+		names[b.Name] = true
+		switch b.Driver {
+		case ontapNAS, ontapNASEconomy, ontapSAN:
+		default:
+			return fmt.Errorf("backend %v: unknown driver: %v, must be one of: %v, %v, %v", b.Name, b.Driver, ontapNAS, ontapNASEconomy, ontapSAN)
+		}
+		if b.ManagementLIF == "" || b.SVM == "" || b.Username == "" || b.Password == "" {
+			return fmt.Errorf("backend %v: ManagementLIF, SVM, Username and Password are required", b.Name)
+		}
+		for _, sc := range b.StorageClasses {
+			if sc.Name == "" {
+				return fmt.Errorf("backend %v: StorageClass Name is required", b.Name)
+			}
+			if sc.Default {
+				defaults++
+			}
+		}
+	}
+	if defaults > 1 {
+		return fmt.Errorf("only one default StorageClass is allowed")
+	}
+	return nil
+}
+
+func (t *tridentAddon) Install() error {
+	return installTrident(t.m)
+}
+
+func (t *tridentAddon) Uninstall() error {
+	m := t.m
+	m.events <- Event{EventType: "progress", Event: "uninstalling the trident addon"}
+	bin, err := m.tridentctl()
+	if err != nil {
+		return err
+	}
+	envs := m.permanentEnvs()
+
+	// the StorageClasses are found by label, so classes since removed from the config go too
+	args := []string{
+		"delete",
+		"storageclass",
+		"--ignore-not-found",
+		"--selector=" + tridentClassLabel + "=true",
+	}
+	err = executeCommand(envs, string(kubectl), args, nil)
+	if err != nil {
+		return err
+	}
+
+	args = []string{"uninstall", "--namespace=" + tridentNamespace}
+	err = executeCommand(envs, bin, args, nil)
+	if err != nil {
+		return err
+	}
+	if !m.Addons.Trident.RemoveCRDs {
+		return nil
+	}
+	// uninstall keeps the CRDs and with them the backends and volume records, obliviate removes them
+	m.events <- Event{EventType: "progress", Event: "removing the trident CRDs"}
+	args = []string{"obliviate", "crd", "--yesireallymeanit", "--namespace=" + tridentNamespace}
+	return executeCommand(envs, bin, args, nil)
+}
+
+func (t *tridentAddon) Status() (AddonStatus, error) {
+	bin, err := t.m.tridentctl()
+	if err != nil {
+		return AddonStatus{}, err
+	}
+	version, err := tridentServerVersion(t.m.permanentEnvs(), bin)
+	if err != nil {
+		return AddonStatus{}, err
+	}
+	if version == "" {
+		return AddonStatus{Details: "trident is not installed"}, nil
+	}
+
+	args := []string{
+		"get",
+		"backend",
+		"--namespace=" + tridentNamespace,
+		"--output=json",
+	}
+	c := cmds.NewCommandLine(t.m.permanentEnvs(), bin, args, nil)
+	stdout, stderr, err := c.Program().Execute()
+	if err != nil {
+		return AddonStatus{}, fmt.Errorf("err: %v, stderr: %v", err, string(stderr))
+	}
+	var backends tridentBackendList
+	err = json.Unmarshal(stdout, &backends)
+	if err != nil {
+		return AddonStatus{}, fmt.Errorf("error with unmarshal: %v", err.Error())
+	}
+	status := AddonStatus{Installed: true, Healthy: true}
+	details := []string{"trident " + version}
+	for _, b := range backends.Items {
+		if b.State != "online" {
+			status.Healthy = false
+		}
+		details = append(details, fmt.Sprintf("backend %v: %v", b.Name, b.State))
+	}
+	status.Details = strings.Join(details, "\n")
+	return status, nil
+}
+
+// tridentctl returns the tridentctl binary, downloading the pinned installer if needed
+func (m *MgmtCluster) tridentctl() (string, error) {
+	version := strings.TrimPrefix(m.Addons.Trident.Version, "v")
+	if version == "" {
+		return string(tridentctl), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ConfigDir, m.ClusterName, "trident-"+version)
+	bin := filepath.Join(dir, "trident-installer", string(tridentctl))
+	if _, err := os.Stat(bin); err == nil {
+		return bin, nil
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	location := m.Addons.Trident.InstallerLocation
+	if location == "" {
+		location = fmt.Sprintf(tridentInstallerURL, version, version)
+	}
+	m.events <- Event{EventType: "progress", Event: "extracting trident installer " + location}
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		_, err = extractRemoteArchive(location, dir)
+	} else {
+		location, err = homedir.Expand(location)
+		if err != nil {
+			return "", err
+		}
+		_, err = extractLocalArchive(location, dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to extract trident installer, %v", err)
+	}
+	if _, err := os.Stat(bin); err != nil {
+		return "", fmt.Errorf("tridentctl not found in trident installer, %v", err)
+	}
+	return bin, nil
+}
+
+// tridentServerVersion returns the installed trident version, or an empty string if trident is not installed
+func tridentServerVersion(envs map[string]string, bin string) (string, error) {
+	args := []string{
+		"version",
+		"--namespace=" + tridentNamespace,
+		"--output=json",
+	}
+	stdout, stderr, err := runCommand(envs, bin, args)
+	if err != nil {
+		if tridentNotFound(string(stdout) + string(stderr)) {
+			return "", nil
+		}
+		return "", fmt.Errorf("unable to get the trident version, err: %v, stderr: %v", err, string(stderr))
+	}
+	var v tridentVersion
+	err = json.Unmarshal(stdout, &v)
+	if err != nil {
+		return "", fmt.Errorf("error with unmarshal: %v", err.Error())
+	}
+	return strings.TrimPrefix(v.Server.Version, "v"), nil
+}
+
+// tridentNotFound reports whether tridentctl output says there is no trident server to query
+func tridentNotFound(output string) bool {
+	output = strings.ToLower(output)
+	return strings.Contains(output, "could not find a trident pod") ||
+		strings.Contains(output, "could not find trident") ||
+		strings.Contains(output, "no trident server")
+}
+
+func installTrident(m *MgmtCluster) error {
+	m.events <- Event{EventType: "progress", Event: "installing the trident addon"}
+	var err error
+	envs := m.permanentEnvs()
+	bin, err := m.tridentctl()
+	if err != nil {
+		return err
+	}
+
+	installed, err := tridentServerVersion(envs, bin)
+	if err != nil {
+		return err
+	}
+	pinned := strings.TrimPrefix(m.Addons.Trident.Version, "v")
+	install := installed == ""
+	if installed != "" && pinned != "" && installed != pinned {
+		// uninstall keeps the CRDs, so existing backends and volumes survive the upgrade
+		m.events <- Event{EventType: "progress", Event: fmt.Sprintf("upgrading trident from %v to %v", installed, pinned)}
+		args := []string{"uninstall", "--namespace=" + tridentNamespace}
+		err = cmds.GenericExecute(envs, bin, args, nil)
+		if err != nil {
+			return err
+		}
+		install = true
+	}
+	if install {
+		args := []string{"install", "--namespace=" + tridentNamespace}
+		err = cmds.GenericExecute(envs, bin, args, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		m.events <- Event{EventType: "progress", Event: "trident " + installed + " already installed"}
+	}
+
+	if m.Addons.Solidfire.Enable {
+		backend, classes, err := elementBackendFiles(m.ClusterName, m.Addons.Solidfire)
+		if err != nil {
+			return err
+		}
+		err = m.applyTridentBackend(bin, elementDriver, backend, classes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.Addons.Trident.Enable {
+		for _, b := range m.Addons.Trident.Backends {
+			backend, classes, err := ontapBackendFiles(b)
+			if err != nil {
+				return err
+			}
+			err = m.applyTridentBackend(bin, b.Name, backend, classes)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	m.events <- Event{EventType: "progress", Event: "trident addon install complete"}
+	return err
+}
+
+// applyTridentBackend creates or updates the backend and applies its StorageClasses
+func (m *MgmtCluster) applyTridentBackend(bin, name string, backend, classes fileOnDisk) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	envs := m.permanentEnvs()
+
+	err = writeToDisk(m.ClusterName, backend.Name, []byte(backend.Contents), 0600)
+	if err != nil {
+		return err
+	}
+	args := []string{
+		"get",
+		"backend",
+		name,
+		"--namespace=" + tridentNamespace,
+	}
+	c := cmds.NewCommandLine(envs, bin, args, nil)
+	_, _, err = c.Program().Execute()
+	if err == nil {
+		m.events <- Event{EventType: "progress", Event: "updating trident backend " + name}
+		args = []string{"--namespace=" + tridentNamespace, "update", "backend", name}
+	} else {
+		m.events <- Event{EventType: "progress", Event: "creating trident backend " + name}
+		args = []string{"--namespace=" + tridentNamespace, "create", "backend"}
+	}
+	args = append(args, "--filename="+filepath.Join(home, ConfigDir, m.ClusterName, backend.Name))
+	err = cmds.GenericExecute(envs, bin, args, nil)
+	if err != nil {
+		return err
+	}
+
+	if classes.Contents == "" {
+		return nil
+	}
+	err = writeToDisk(m.ClusterName, classes.Name, []byte(classes.Contents), 0644)
+	if err != nil {
+		return err
+	}
+	args = []string{
+		"--namespace=default",
+		"--output=json",
+		"apply",
+		"--filename=" + filepath.Join(home, ConfigDir, m.ClusterName, classes.Name),
+	}
+	return cmds.GenericExecute(envs, string(kubectl), args, nil)
+}
+
+// ontapBackendFiles generates the backend JSON and StorageClasses for an ONTAP backend
+func ontapBackendFiles(b TridentBackend) (fileOnDisk, fileOnDisk, error) {
+	backend, err := json.MarshalIndent(ontapBackend{
+		Version:           1,
+		StorageDriverName: b.Driver,
+		BackendName:       b.Name,
+		ManagementLIF:     b.ManagementLIF,
+		DataLIF:           b.DataLIF,
+		SVM:               b.SVM,
+		Username:          b.Username,
+		Password:          b.Password,
+	}, "", "  ")
+	if err != nil {
+		return fileOnDisk{}, fileOnDisk{}, err
+	}
+
+	var docs []string
+	for _, c := range b.StorageClasses {
+		sc := storagev1.StorageClass{
+			TypeMeta:    metav1.TypeMeta{APIVersion: "storage.k8s.io/v1", Kind: "StorageClass"},
+			ObjectMeta:  metav1.ObjectMeta{Name: c.Name, Labels: map[string]string{tridentClassLabel: "true"}},
+			Provisioner: "netapp.io/trident",
+			Parameters: map[string]string{
+				"backendType":  b.Driver,
+				"storagePools": b.Name + ":.*",
+			},
+		}
+		if c.FSType != "" {
+			sc.Parameters["fsType"] = c.FSType
+		}
+		if c.Default {
+			sc.Annotations = map[string]string{defaultClassAnnotation: "true"}
+		}
+		doc, err := yaml.Marshal(sc)
+		if err != nil {
+			return fileOnDisk{}, fileOnDisk{}, err
+		}
+		docs = append(docs, string(doc))
+	}
+
+	return fileOnDisk{Name: fmt.Sprintf(tridentBackend, b.Name), Contents: string(backend)},
+		fileOnDisk{Name: fmt.Sprintf(tridentClasses, b.Name), Contents: strings.Join(docs, "---\n")},
+		nil
+}
+
+// tridentPrereqPatches adds the storage network to the machine templates and iSCSI packages to CAPI machines
+func tridentPrereqPatches(clusterName, storageNetwork string, machineTemplates []string) []kustomizePatch {
+	var patches []kustomizePatch
+	network := fileOnDisk{Name: PatchFileOne.Name, Contents: fmt.Sprintf(PatchFileOne.Contents, storageNetwork)}
+	for _, t := range machineTemplates {
+		patches = append(patches, kustomizePatch{
+			Target: machineTemplateTarget(t),
+			File:   network,
+		})
+	}
+	return append(patches,
+		kustomizePatch{
+			Target: controlPlaneTarget(clusterName),
+			File:   PatchFileTwo,
+		},
+		kustomizePatch{
+			Target: configTemplateTarget(clusterName + "-md-0"),
+			File:   PatchFileThree,
+		},
+	)
+}
@@ -0,0 +1,63 @@
+package capv
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// vcenterThumbprint validates the vCenter certificate settings and returns the thumbprint colon separated
+// in upper case, the way CAPV expects it. The CAPV controllers only pin SHA-1 thumbprints, they cannot be
+// given a CA bundle, so VcenterCABundle needs VcenterThumbprint too.
+func (m *MgmtCluster) vcenterThumbprint() (string, error) {
+	if m.VcenterInsecure && (m.VcenterThumbprint != "" || m.VcenterCABundle != "") {
+		return "", fmt.Errorf("VcenterInsecure cannot be set with VcenterThumbprint or VcenterCABundle")
+	}
+	if m.VcenterCABundle != "" && m.VcenterThumbprint == "" {
+		return "", fmt.Errorf("VcenterCABundle requires VcenterThumbprint, the CAPV controllers only verify vCenter by its thumbprint")
+	}
+	if m.VcenterThumbprint == "" {
+		return "", nil
+	}
+	b, err := hex.DecodeString(strings.ReplaceAll(m.VcenterThumbprint, ":", ""))
+	if err != nil || len(b) != sha1.Size {
+		return "", fmt.Errorf("invalid VcenterThumbprint %v, expected a SHA-1 thumbprint", m.VcenterThumbprint)
+	}
+	hex := make([]string, len(b))
+	for i, c := range b {
+		hex[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(hex, ":"), nil
+}
+
+// trustPatches passes the vCenter thumbprint, or the insecure opt-in, to the VSphereCluster and its cloud provider configuration
+func (m *MgmtCluster) trustPatches() ([]kustomizePatch, error) {
+	thumbprint, err := m.vcenterThumbprint()
+	if err != nil {
+		return nil, err
+	}
+	var ops []jsonPatchOp
+	switch {
+	case thumbprint != "":
+		ops = []jsonPatchOp{
+			{Op: "add", Path: "/spec/thumbprint", Value: thumbprint},
+			{Op: "add", Path: "/spec/cloudProviderConfiguration/global/thumbprint", Value: thumbprint},
+			{Op: "add", Path: "/spec/cloudProviderConfiguration/global/insecure", Value: false},
+		}
+	case m.VcenterInsecure:
+		ops = []jsonPatchOp{{Op: "add", Path: "/spec/cloudProviderConfiguration/global/insecure", Value: true}}
+	default:
+		return nil, nil
+	}
+	contents, err := yaml.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	return []kustomizePatch{{
+		Target: vsphereClusterTarget(m.ClusterName),
+		File:   fileOnDisk{Name: "patch-vcenter-trust.yaml", Contents: string(contents)},
+	}}, nil
+}
@@ -0,0 +1,58 @@
+package capv
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTrustPatches(t *testing.T) {
+	m := &MgmtCluster{}
+	m.ClusterName = "test"
+	m.VcenterThumbprint = "ab:cd:ef:01:23:45:67:89:ab:cd:ef:01:23:45:67:89:ab:cd:ef:01"
+	patches, err := m.trustPatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 || patches[0].Target.Kind != "VSphereCluster" {
+		t.Fatalf("unexpected patches: %+v", patches)
+	}
+	var ops []jsonPatchOp
+	err = yaml.Unmarshal([]byte(patches[0].File.Contents), &ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 || ops[0].Value != "AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01" || ops[2].Value != false {
+		t.Fatalf("unexpected trust patch:\n%v", patches[0].File.Contents)
+	}
+
+	// the CA bundle is for the cluster-engine client, the controllers still pin the thumbprint
+	m.VcenterCABundle = "/etc/ssl/vcenter-ca.pem"
+	patches, err = m.trustPatches()
+	if err != nil || len(patches) != 1 {
+		t.Fatalf("expected the thumbprint patch with a CA bundle, got %v, %v", patches, err)
+	}
+
+	m.VcenterThumbprint = ""
+	m.VcenterCABundle = ""
+	patches, err = m.trustPatches()
+	if err != nil || len(patches) != 0 {
+		t.Fatalf("expected no patches, got %v, %v", patches, err)
+	}
+}
+
+func TestTrustPatchesInvalid(t *testing.T) {
+	tests := map[string]Vsphere{
+		"SHA-256":               {VcenterThumbprint: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		"not hex":               {VcenterThumbprint: "not-a-thumbprint"},
+		"insecure with pinning": {VcenterInsecure: true, VcenterThumbprint: "abcdef0123456789abcdef0123456789abcdef01"},
+		"CA bundle only":        {VcenterCABundle: "/etc/ssl/vcenter-ca.pem"},
+	}
+	for name, v := range tests {
+		m := &MgmtCluster{}
+		m.Vsphere = v
+		if _, err := m.trustPatches(); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
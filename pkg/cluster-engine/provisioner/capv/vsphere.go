@@ -0,0 +1,75 @@
+package capv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/netapp/cake/pkg/platform/vsphere"
+)
+
+// InventoryPaths returns the vSphere inventory named in the config, the networks are the management,
+// workload and storage networks in that order without repeats
+func (v *Vsphere) InventoryPaths() vsphere.InventoryPaths {
+	paths := vsphere.InventoryPaths{
+		Datacenter:   v.Datacenter,
+		Datastore:    v.Datastore,
+		Folder:       v.Folder,
+		ResourcePool: v.ResourcePool,
+	}
+	seen := map[string]bool{}
+	for _, n := range []string{v.ManagementNetwork, v.WorkloadNetwork, v.StorageNetwork} {
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		paths.Networks = append(paths.Networks, n)
+	}
+	return paths
+}
+
+// ManagerOptions returns how the vCenter certificate is verified
+func (v *Vsphere) ManagerOptions() []vsphere.ManagerOption {
+	var opts []vsphere.ManagerOption
+	if v.VcenterInsecure {
+		opts = append(opts, vsphere.WithInsecure())
+	}
+	if v.VcenterCABundle != "" {
+		opts = append(opts, vsphere.WithCABundle(v.VcenterCABundle))
+	}
+	if v.VcenterThumbprint != "" {
+		opts = append(opts, vsphere.WithThumbprint(v.VcenterThumbprint))
+	}
+	return opts
+}
+
+// Infrastructure logs in to vCenter and resolves the inventory named in the config
+func (v *Vsphere) Infrastructure(ctx context.Context) (*vsphere.Resource, error) {
+	server := v.VcenterServer
+	if !strings.Contains(server, "://") {
+		server = "https://" + server + "/"
+	}
+	sm, err := vsphere.NewManager(ctx, server, v.VsphereUsername, v.VspherePassword, v.ManagerOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to vCenter %v, %v", v.VcenterServer, err)
+	}
+	infra, err := sm.ResolveInfrastructure(ctx, v.InventoryPaths())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve the vSphere inventory, %v", err)
+	}
+	return &vsphere.Resource{Infrastructure: *infra, SessionManager: sm}, nil
+}
+
+// nodeTemplateDiskGiB looks up the disk size of the NodeTemplate VM in vCenter, tests swap it for a fake
+var nodeTemplateDiskGiB = func(m *MgmtCluster) (int, error) {
+	ctx := context.Background()
+	r, err := m.Infrastructure(ctx)
+	if err != nil {
+		return 0, err
+	}
+	template, err := r.SessionManager.GetVM(ctx, r.Datacenter, m.NodeTemplate)
+	if err != nil {
+		return 0, fmt.Errorf("unable to find the node template %v, %v", m.NodeTemplate, err)
+	}
+	return vsphere.DiskGiB(ctx, template)
+}
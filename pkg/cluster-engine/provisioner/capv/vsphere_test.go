@@ -0,0 +1,73 @@
+package capv
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+)
+
+func TestInventoryPaths(t *testing.T) {
+	v := Vsphere{
+		Datacenter:        "dc",
+		ResourcePool:      "*/Resources",
+		ManagementNetwork: "mgmt",
+		WorkloadNetwork:   "mgmt",
+		StorageNetwork:    "storage",
+	}
+	paths := v.InventoryPaths()
+	if paths.Datacenter != "dc" || paths.ResourcePool != "*/Resources" {
+		t.Fatalf("unexpected paths: %+v", paths)
+	}
+	if len(paths.Networks) != 2 || paths.Networks[0] != "mgmt" || paths.Networks[1] != "storage" {
+		t.Fatalf("unexpected networks: %v", paths.Networks)
+	}
+}
+
+func TestVsphereInfrastructure(t *testing.T) {
+	model := simulator.VPX()
+	err := model.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	s := model.Service.NewServer()
+	defer s.Close()
+
+	password, _ := s.URL.User.Password()
+	server := url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host, Path: "/"}
+	v := Vsphere{
+		Datacenter:        "DC0",
+		Datastore:         "LocalDS_0",
+		Folder:            "vm",
+		ResourcePool:      "DC0_C0/Resources",
+		ManagementNetwork: "VM Network",
+		VcenterServer:     server.String(),
+		VsphereUsername:   s.URL.User.Username(),
+		VspherePassword:   password,
+	}
+	r, err := v.Infrastructure(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ResourcePool.InventoryPath != "/DC0/host/DC0_C0/Resources" || r.Network == nil {
+		t.Fatalf("unexpected infrastructure: %+v", r.Infrastructure)
+	}
+
+	m := &MgmtCluster{Vsphere: v}
+	m.NodeTemplate = "DC0_H0_VM0"
+	size, err := nodeTemplateDiskGiB(m)
+	if err != nil || size == 0 {
+		t.Fatalf("expected the node template disk size, got %v, %v", size, err)
+	}
+	m.NodeTemplate = "missing"
+	if _, err := nodeTemplateDiskGiB(m); err == nil {
+		t.Fatal("expected a missing node template to fail")
+	}
+
+	v.ResourcePool = "*/Resources"
+	if _, err := v.Infrastructure(context.Background()); err == nil {
+		t.Fatal("expected an ambiguous resource pool to fail")
+	}
+}
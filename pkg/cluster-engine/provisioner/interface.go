@@ -4,6 +4,7 @@ package provisioner
 type Cluster interface {
 	CreateBootstrap() error
 	InstallControlPlane() error
+	DryRun() ([]byte, error)
 	CreatePermanent() error
 	PivotControlPlane() error
 	InstallAddons() error
@@ -19,7 +20,12 @@ type MgmtCluster struct {
 	SSHAuthorizedKey         string `yaml:"SshAuthorizedKey"`
 	ControlPlaneMachineCount string `yaml:"ControlPlaneMachineCount"`
 	WorkerMachineCount       string `yaml:"WorkerMachineCount"`
-	LogFile                  string `yaml:"LogFile"`
+	// ControlPlaneMachineSize and WorkerMachineSize are small, medium, large or a custom size
+	// like cpus=4,memoryMiB=8192,diskGiB=60, empty keeps the size of the node template.
+	// They take the same values as the MasterSize and WorkerSize of the NKS ClusterSpec.
+	ControlPlaneMachineSize string `yaml:"ControlPlaneMachineSize"`
+	WorkerMachineSize       string `yaml:"WorkerMachineSize"`
+	LogFile                 string `yaml:"LogFile"`
 }
 
 // K8s spec
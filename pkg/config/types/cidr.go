@@ -0,0 +1,35 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// ValidateCIDRs checks that the named CIDRs are valid and do not overlap each other, empty CIDRs are ignored
+func ValidateCIDRs(cidrs map[string]string) error {
+	var names []string
+	networks := map[string]*net.IPNet{}
+	for name, cidr := range cidrs {
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid %v %v, %v", name, cidr, err)
+		}
+		names = append(names, name)
+		networks[name] = network
+	}
+	sort.Strings(names)
+
+	for i := 0; i < len(names); i++ {
+		for j := i + 1; j < len(names); j++ {
+			a, b := networks[names[i]], networks[names[j]]
+			if a.Contains(b.IP) || b.Contains(a.IP) {
+				return fmt.Errorf("%v %v overlaps with %v %v", names[i], a, names[j], b)
+			}
+		}
+	}
+	return nil
+}
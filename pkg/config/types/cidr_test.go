@@ -0,0 +1,24 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestValidateCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidrs   map[string]string
+		wantErr bool
+	}{
+		{"disjoint", map[string]string{"pods": "10.200.0.0/16", "services": "10.96.0.0/12"}, false},
+		{"empty ignored", map[string]string{"pods": "10.200.0.0/16", "services": ""}, false},
+		{"overlap", map[string]string{"pods": "192.168.0.0/16", "network": "192.168.10.0/24"}, true},
+		{"invalid", map[string]string{"pods": "192.168.0.0"}, true},
+	}
+	for _, tt := range tests {
+		err := ValidateCIDRs(tt.cidrs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: got err %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
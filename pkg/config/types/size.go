@@ -0,0 +1,76 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VMSize presets
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+)
+
+// VMSize is the compute and disk size of a VM, zero values keep the size of the template
+type VMSize struct {
+	CPUs      int32 `yaml:"CPUs,omitempty" json:"cpus,omitempty"`
+	MemoryMiB int64 `yaml:"MemoryMiB,omitempty" json:"memorymib,omitempty"`
+	DiskGiB   int32 `yaml:"DiskGiB,omitempty" json:"diskgib,omitempty"`
+}
+
+var vmSizePresets = map[string]VMSize{
+	SizeSmall:  {CPUs: 2, MemoryMiB: 4096, DiskGiB: 25},
+	SizeMedium: {CPUs: 4, MemoryMiB: 8192, DiskGiB: 50},
+	SizeLarge:  {CPUs: 8, MemoryMiB: 16384, DiskGiB: 100},
+}
+
+// IsZero is true when the size keeps the template as is
+func (s VMSize) IsZero() bool {
+	return s == VMSize{}
+}
+
+// ParseVMSize parses a preset name (small, medium or large) or a custom size
+// such as "cpus=4,memoryMiB=8192,diskGiB=60", an empty string is the zero size
+func ParseVMSize(size string) (VMSize, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return VMSize{}, nil
+	}
+	if preset, ok := vmSizePresets[strings.ToLower(size)]; ok {
+		return preset, nil
+	}
+	if !strings.Contains(size, "=") {
+		var presets []string
+		for name := range vmSizePresets {
+			presets = append(presets, name)
+		}
+		sort.Strings(presets)
+		return VMSize{}, fmt.Errorf("unknown size %v, must be one of %v or a custom size like cpus=4,memoryMiB=8192,diskGiB=60", size, strings.Join(presets, ", "))
+	}
+
+	var result VMSize
+	for _, field := range strings.Split(size, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return VMSize{}, fmt.Errorf("invalid size field %q, expected key=value", field)
+		}
+		value, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil || value <= 0 {
+			return VMSize{}, fmt.Errorf("invalid size field %q, value must be a positive integer", field)
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "cpus":
+			result.CPUs = int32(value)
+		case "memorymib":
+			result.MemoryMiB = value
+		case "diskgib":
+			result.DiskGiB = int32(value)
+		default:
+			return VMSize{}, fmt.Errorf("unknown size field %v, must be one of cpus, memoryMiB, diskGiB", kv[0])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestParseVMSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    VMSize
+		wantErr bool
+	}{
+		{"", VMSize{}, false},
+		{"medium", VMSize{CPUs: 4, MemoryMiB: 8192, DiskGiB: 50}, false},
+		{"Large", VMSize{CPUs: 8, MemoryMiB: 16384, DiskGiB: 100}, false},
+		{"cpus=6, memoryMiB=12288", VMSize{CPUs: 6, MemoryMiB: 12288}, false},
+		{"cpus=2,memoryMiB=4096,diskGiB=40", VMSize{CPUs: 2, MemoryMiB: 4096, DiskGiB: 40}, false},
+		{"huge", VMSize{}, true},
+		{"cpus=0", VMSize{}, true},
+		{"gpus=1", VMSize{}, true},
+		{"cpus", VMSize{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVMSize(tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got err %v, wantErr %v", tt.size, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	configtypes "github.com/netapp/cake/pkg/config/types"
+	"github.com/netapp/cake/pkg/platform/vsphere/cloudinit"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/object"
+)
+
+const (
+	defaultCloneConcurrency = 4
+)
+
+// Clone states reported in CloneEvent.State
+const (
+	CloneStarted    = "started"
+	CloneCompleted  = "completed"
+	CloneFailed     = "failed"
+	CloneRolledBack = "rolled back"
+)
+
+// CloneSpec describes a VM to clone from a template
+type CloneSpec struct {
+	Name       string
+	BootScript string
+	PublicKey  string
+	OSUser     string
+	// Size of the VM, the zero size keeps the template's size
+	Size configtypes.VMSize
+	// Networks are the cloud-init network configs of the NICs, one per Resource network,
+	// the NICs are left to the template when empty
+	Networks []cloudinit.NetworkConfig
+	// Wait for the guest IP addresses after power on, when set
+	Wait *WaitOptions
+}
+
+// CloneResult is the outcome of cloning one VM. VM is nil if the clone task failed or the VM was rolled back,
+// without Rollback VM is also set next to Err when a step after the clone task failed, e.g. the power on,
+// and the caller has to delete it.
+type CloneResult struct {
+	Name string
+	VM   *ClonedVM
+	Err  error
+}
+
+// CloneEvent reports the progress of one VM in a batch
+type CloneEvent struct {
+	Name  string
+	State string
+	Err   error
+}
+
+// BatchOptions controls CloneTemplates
+type BatchOptions struct {
+	// Concurrency is the maximum number of clones in flight, defaults to 4
+	Concurrency int
+	// Rollback deletes the VMs already created when any clone fails, clones not yet started are skipped
+	// and clone tasks in flight are waited for, so their VMs are deleted too
+	Rollback bool
+	// Progress receives an event each time a VM changes state, it must be drained by the caller
+	Progress chan<- CloneEvent
+}
+
+// CloneTemplates clones the template once per spec, running up to opts.Concurrency clones at a time.
+// The results are in the order of specs, the error is set when any clone failed.
+// The rollback runs with ctx, VMs it cannot delete once ctx is done are listed in the error.
+func (r *Resource) CloneTemplates(ctx context.Context, template *object.VirtualMachine, specs []CloneSpec, opts BatchOptions) ([]CloneResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultCloneConcurrency
+	}
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	names := map[string]bool{}
+	for _, s := range specs {
+		if names[s.Name] {
+			return nil, fmt.Errorf("duplicate VM name in batch: %v", s.Name)
+		}
+		names[s.Name] = true
+	}
+
+	progress := func(name, state string, err error) {
+		if opts.Progress != nil {
+			opts.Progress <- CloneEvent{Name: name, State: state, Err: err}
+		}
+	}
+
+	results := make([]CloneResult, len(specs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, s := range specs {
+		results[i].Name = s.Name
+		wg.Add(1)
+		go func(i int, s CloneSpec) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-batchCtx.Done():
+				results[i].Err = fmt.Errorf("clone of %v not started, %v", s.Name, batchCtx.Err())
+				progress(s.Name, CloneFailed, results[i].Err)
+				return
+			}
+			defer func() { <-sem }()
+			// a slot may free up at the same time the batch is cancelled
+			if batchCtx.Err() != nil {
+				results[i].Err = fmt.Errorf("clone of %v not started, %v", s.Name, batchCtx.Err())
+				progress(s.Name, CloneFailed, results[i].Err)
+				return
+			}
+
+			progress(s.Name, CloneStarted, nil)
+			// the clone task runs with ctx, a task cut short by the rollback would leave its VM behind
+			vm, err := r.cloneTemplate(batchCtx, ctx, template, s)
+			results[i].VM = vm
+			if err != nil {
+				results[i].Err = err
+				progress(s.Name, CloneFailed, err)
+				if opts.Rollback {
+					cancel()
+				}
+				return
+			}
+			progress(s.Name, CloneCompleted, nil)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return results, nil
+	}
+	err := fmt.Errorf("%d of %d clones failed: %v", len(failed), len(specs), failed)
+
+	if opts.Rollback {
+		var leaked []string
+		for i := range results {
+			if results[i].VM == nil {
+				continue
+			}
+			log.Debugf("rolling back VM %s", results[i].Name)
+			if rerr := DeleteVM(ctx, results[i].VM.VirtualMachine); rerr != nil {
+				log.Errorf("unable to roll back VM %s, %v", results[i].Name, rerr)
+				leaked = append(leaked, results[i].Name)
+				continue
+			}
+			results[i].VM = nil
+			progress(results[i].Name, CloneRolledBack, nil)
+		}
+		if len(leaked) > 0 {
+			err = fmt.Errorf("%v, unable to roll back: %v", err, leaked)
+		}
+	}
+
+	return results, err
+}
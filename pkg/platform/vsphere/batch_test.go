@@ -0,0 +1,134 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCloneTemplates(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	var specs []CloneSpec
+	for i := 0; i < 5; i++ {
+		specs = append(specs, CloneSpec{Name: fmt.Sprintf("batch-%d", i), OSUser: "capv"})
+	}
+	progress := make(chan CloneEvent, 2*len(specs))
+	results, err := r.CloneTemplates(ctx, template, specs, BatchOptions{Concurrency: 2, Progress: progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(progress)
+
+	for i, result := range results {
+		if result.Name != specs[i].Name || result.Err != nil || result.VM == nil {
+			t.Fatalf("unexpected result %d: %+v", i, result)
+		}
+		if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, result.Name); err != nil {
+			t.Fatalf("clone %v not found, %v", result.Name, err)
+		}
+	}
+	completed := 0
+	for e := range progress {
+		if e.State == CloneCompleted {
+			completed++
+		}
+	}
+	if completed != len(specs) {
+		t.Fatalf("expected %d completed events, got %d", len(specs), completed)
+	}
+}
+
+func TestCloneTemplatesRollback(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	// the existing VM name makes its clone fail
+	specs := []CloneSpec{{Name: "rollback-0"}, {Name: "DC0_C0_RP0_VM1"}}
+	results, err := r.CloneTemplates(ctx, template, specs, BatchOptions{Concurrency: 1, Rollback: true})
+	if err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	if results[1].Err == nil {
+		t.Fatalf("expected the duplicate clone to fail: %+v", results[1])
+	}
+	if results[0].VM != nil {
+		t.Fatalf("expected %v to be rolled back", results[0].Name)
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "rollback-0"); err == nil {
+		t.Fatal("rolled back VM still exists")
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "DC0_C0_RP0_VM1"); err != nil {
+		t.Fatalf("existing VM was removed, %v", err)
+	}
+}
+
+func TestCloneTemplatesRollbackInFlight(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	// vcsim reports no guest addresses, the clones wait for one until the invalid CIDR fails the batch
+	waiting := &WaitOptions{Timeout: time.Minute, CIDRs: []string{"192.0.2.0/24"}}
+	specs := []CloneSpec{
+		{Name: "inflight-0", Wait: waiting},
+		{Name: "inflight-1", Wait: &WaitOptions{CIDRs: []string{"not-a-cidr"}}},
+		{Name: "inflight-2", Wait: waiting},
+	}
+	progress := make(chan CloneEvent, 3*len(specs))
+	results, err := r.CloneTemplates(ctx, template, specs, BatchOptions{Concurrency: len(specs), Rollback: true, Progress: progress})
+	if err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	close(progress)
+	for _, result := range results {
+		if result.VM != nil {
+			t.Fatalf("expected %v to be rolled back: %+v", result.Name, result)
+		}
+		if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, result.Name); err == nil {
+			t.Fatalf("VM %v left behind by the rollback", result.Name)
+		}
+	}
+	started, rolledBack := 0, 0
+	for e := range progress {
+		switch e.State {
+		case CloneStarted:
+			started++
+		case CloneRolledBack:
+			rolledBack++
+		}
+	}
+	if started != len(specs) || rolledBack != len(specs) {
+		t.Fatalf("expected every clone to run and be rolled back, %d started, %d rolled back", started, rolledBack)
+	}
+}
+
+func TestCloneTemplatesDuplicateNames(t *testing.T) {
+	ctx := context.Background()
+	r := &Resource{}
+	_, err := r.CloneTemplates(ctx, nil, []CloneSpec{{Name: "a"}, {Name: "a"}}, BatchOptions{})
+	if err == nil {
+		t.Fatal("expected duplicate names to be rejected")
+	}
+}
+
+func TestCloneTemplatesCancelled(t *testing.T) {
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := r.CloneTemplates(ctx, template, []CloneSpec{{Name: "cancelled-0"}, {Name: "cancelled-1"}}, BatchOptions{})
+	if err == nil {
+		t.Fatal("expected a cancelled batch to fail")
+	}
+	for _, result := range results {
+		if result.Err == nil || result.VM != nil {
+			t.Fatalf("expected %v not to be cloned: %+v", result.Name, result)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// localOVA returns a local copy of the OVA at ovaPath. Remote OVAs are downloaded into opts.CacheDir,
+// or without one into a temporary directory that cleanup removes.
+func localOVA(ctx context.Context, client *govmomi.Client, ovaPath string, opts OVAOptions) (string, func(), error) {
+	cleanup := func() {}
+	if !isRemotePath(ovaPath) {
+		if opts.SHA256 != "" {
+			if err := checkSHA256(ovaPath, opts.SHA256); err != nil {
+				return "", cleanup, err
+			}
+		}
+		return ovaPath, cleanup, nil
+	}
+
+	dir := opts.CacheDir
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "ova-")
+		if err != nil {
+			return "", cleanup, err
+		}
+		dir = tmp
+		cleanup = func() { os.RemoveAll(tmp) }
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", cleanup, fmt.Errorf("unable to create OVA cache %s, %v", dir, err)
+	}
+
+	location := filepath.Join(dir, cacheKey(ovaPath, opts.SHA256))
+	if _, err := os.Stat(location); err == nil {
+		if opts.SHA256 == "" {
+			log.Debugf("using cached OVA %s for %s", location, ovaPath)
+			return location, cleanup, nil
+		}
+		if err := checkSHA256(location, opts.SHA256); err == nil {
+			log.Debugf("using cached OVA %s for %s", location, ovaPath)
+			return location, cleanup, nil
+		}
+		log.Debugf("cached OVA %s does not match its digest, downloading it again", location)
+	}
+
+	err := download(ctx, client, ovaPath, location, opts.SHA256)
+	if err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	return location, cleanup, nil
+}
+
+// cacheKey names the cache file of an OVA by its digest, or by its URL without one
+func cacheKey(link, digest string) string {
+	if digest != "" {
+		return "sha256-" + strings.ToLower(digest) + ".ova"
+	}
+	sum := sha256.Sum256([]byte(link))
+	return "url-" + hex.EncodeToString(sum[:]) + ".ova"
+}
+
+// download writes the OVA at link to location, the file only appears once complete and matching digest
+func download(ctx context.Context, client *govmomi.Client, link, location, digest string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return fmt.Errorf("Error parsing url %s, %w", link, err)
+	}
+	log.Debugf("downloading OVA %s to %s", link, location)
+	body, _, err := client.Client.Download(ctx, u, &soap.DefaultDownload)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := ioutil.TempFile(filepath.Dir(location), filepath.Base(location)+".part-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	sum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, sum), body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to download %s, %v", link, err)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); digest != "" && !strings.EqualFold(got, digest) {
+		return fmt.Errorf("SHA256 of %s is %s, expected %s", link, got, digest)
+	}
+	return os.Rename(f.Name(), location)
+}
+
+// checkSHA256 checks the SHA-256 digest of the file at location
+func checkSHA256(location, digest string) error {
+	f, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); !strings.EqualFold(got, digest) {
+		return fmt.Errorf("SHA256 of %s is %s, expected %s", location, got, digest)
+	}
+	return nil
+}
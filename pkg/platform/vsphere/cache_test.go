@@ -0,0 +1,133 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalOVACache(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	client, err := r.SessionManager.GetClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ova_cache_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ova, err := ioutil.ReadFile(writeTestOVA(t, dir, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := fmt.Sprintf("%x", sha256.Sum256(ova))
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		downloads++
+		w.Write(ova)
+	}))
+	link := server.URL + "/test.ova"
+	cacheDir := filepath.Join(dir, "cache")
+
+	location, done, err := localOVA(ctx, client, link, OVAOptions{CacheDir: cacheDir, SHA256: digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+	if filepath.Dir(location) != cacheDir || filepath.Base(location) != cacheKey(link, digest) {
+		t.Fatalf("unexpected cache location %v", location)
+	}
+
+	// the cached copy is used once the server is gone
+	server.Close()
+	again, done, err := localOVA(ctx, client, link, OVAOptions{CacheDir: cacheDir, SHA256: digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+	if again != location || downloads != 1 {
+		t.Fatalf("expected the cached OVA, got %v after %v downloads", again, downloads)
+	}
+
+	_, _, err = localOVA(ctx, client, filepath.Join(dir, "test.ova"), OVAOptions{SHA256: fmt.Sprintf("%x", sha256.Sum256(nil))})
+	if err == nil {
+		t.Fatal("expected a local OVA with the wrong digest to fail")
+	}
+}
+
+func TestLocalOVADigestMismatch(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	client, err := r.SessionManager.GetClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ova_cache_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("not an ova"))
+	}))
+	defer server.Close()
+
+	_, _, err = localOVA(ctx, client, server.URL+"/test.ova", OVAOptions{CacheDir: dir, SHA256: fmt.Sprintf("%x", sha256.Sum256(nil))})
+	if err == nil {
+		t.Fatal("expected a digest mismatch to fail")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("expected nothing to be cached, got %v, %v", len(files), err)
+	}
+}
+
+func TestLocalOVACABundle(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "ova_cache_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ova, err := ioutil.ReadFile(writeTestOVA(t, dir, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the OVA server stands in for a public HTTPS server, its certificate is one of the system CAs
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(ova)
+	}))
+	defer server.Close()
+	defer func(f func() (*x509.CertPool, error)) { systemCertPool = f }(systemCertPool)
+	systemCertPool = func() (*x509.CertPool, error) {
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+		return pool, nil
+	}
+
+	// the simulator client trusts the vCenter CA bundle
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	client, err := r.SessionManager.GetClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, done, err := localOVA(ctx, client, server.URL+"/test.ova", OVAOptions{CacheDir: filepath.Join(dir, "cache")})
+	if err != nil {
+		t.Fatalf("expected the system CAs to verify the OVA server with a CA bundle, %v", err)
+	}
+	done()
+}
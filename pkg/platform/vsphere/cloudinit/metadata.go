@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"net"
 	"text/template"
 
 	"github.com/vmware/govmomi/vim25/types"
@@ -24,6 +25,38 @@ type NetworkConfig struct {
 	DNSSearch   []string
 }
 
+// Validate checks the addresses of a static network config, IPAddress may be in CIDR notation instead of using Netmask
+func (n NetworkConfig) Validate() error {
+	if n.DHCP4 {
+		return nil
+	}
+	if n.IPAddress == "" {
+		return fmt.Errorf("an IPAddress is required without DHCP4")
+	}
+	if net.ParseIP(n.IPAddress) == nil {
+		if _, _, err := net.ParseCIDR(n.IPAddress); err != nil {
+			return fmt.Errorf("invalid IPAddress %v", n.IPAddress)
+		}
+		if n.Netmask != "" {
+			return fmt.Errorf("IPAddress %v is in CIDR notation, Netmask must be empty", n.IPAddress)
+		}
+	} else if n.Netmask == "" {
+		return fmt.Errorf("a Netmask is required for IPAddress %v", n.IPAddress)
+	}
+	if n.Netmask != "" && net.ParseIP(n.Netmask) == nil {
+		return fmt.Errorf("invalid Netmask %v", n.Netmask)
+	}
+	if n.Gateway != "" && net.ParseIP(n.Gateway) == nil {
+		return fmt.Errorf("invalid Gateway %v", n.Gateway)
+	}
+	for _, ns := range n.NameServers {
+		if net.ParseIP(ns) == nil {
+			return fmt.Errorf("invalid NameServer %v", ns)
+		}
+	}
+	return nil
+}
+
 // SetCloudInitMetadata sets the cloud init user data at the key
 // "guestinfo.metadata" as a base64-encoded string.
 func (e *Config) SetCloudInitMetadata(data []byte) error {
@@ -0,0 +1,209 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+)
+
+// InventoryPaths names the objects of an Infrastructure. Each one is a name, which is searched for
+// anywhere in the datacenter, or an inventory path, e.g. "*/Resources" or "/DC0/vm/k8s".
+type InventoryPaths struct {
+	Datacenter   string
+	Datastore    string
+	Folder       string
+	ResourcePool string
+	// Networks are attached to clones in order, the first one is also the Infrastructure Network
+	Networks []string
+}
+
+// NotFoundError is returned when nothing in the inventory matches a name or path
+type NotFoundError struct {
+	Kind string
+	Path string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", e.Kind, e.Path)
+}
+
+// MultipleFoundError is returned when a name or path matches more than one object in the inventory
+type MultipleFoundError struct {
+	Kind    string
+	Path    string
+	Matches []string
+}
+
+func (e *MultipleFoundError) Error() string {
+	return fmt.Sprintf("%s %q matches %d objects, use one of the inventory paths: %s", e.Kind, e.Path, len(e.Matches), strings.Join(e.Matches, ", "))
+}
+
+// single checks that listing path found exactly one object, matches are the inventory paths found
+func single(kind, path string, matches []string, err error) error {
+	if path == "" {
+		return fmt.Errorf("a %s name or path is required", kind)
+	}
+	if _, ok := err.(*find.NotFoundError); ok {
+		return &NotFoundError{Kind: kind, Path: path}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to find %s %q, %v", kind, path, err)
+	}
+	if len(matches) == 0 {
+		return &NotFoundError{Kind: kind, Path: path}
+	}
+	if len(matches) > 1 {
+		return &MultipleFoundError{Kind: kind, Path: path, Matches: matches}
+	}
+	return nil
+}
+
+// finder returns a finder scoped to the datacenter dc, or to the whole inventory when dc is nil
+func (m *sessionManager) finder(ctx context.Context, dc *object.Datacenter) (*find.Finder, error) {
+	client, err := m.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	finder := find.NewFinder(client.Client, true)
+	if dc != nil {
+		finder.SetDatacenter(dc)
+	}
+	return finder, nil
+}
+
+// GetDatacenter returns the datacenter named name
+func (m *sessionManager) GetDatacenter(ctx context.Context, name string) (*object.Datacenter, error) {
+	finder, err := m.finder(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var datacenters []*object.Datacenter
+	var matches []string
+	if name != "" {
+		datacenters, err = finder.DatacenterList(ctx, name)
+	}
+	for _, o := range datacenters {
+		matches = append(matches, o.InventoryPath)
+	}
+	if err := single("datacenter", name, matches, err); err != nil {
+		return nil, err
+	}
+	return datacenters[0], nil
+}
+
+// GetDatastore returns the datastore in dc named path
+func (m *sessionManager) GetDatastore(ctx context.Context, dc *object.Datacenter, path string) (*object.Datastore, error) {
+	finder, err := m.finder(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+	var datastores []*object.Datastore
+	var matches []string
+	if path != "" {
+		datastores, err = finder.DatastoreList(ctx, path)
+	}
+	for _, o := range datastores {
+		matches = append(matches, o.InventoryPath)
+	}
+	if err := single("datastore", path, matches, err); err != nil {
+		return nil, err
+	}
+	return datastores[0], nil
+}
+
+// GetFolder returns the folder in dc named path, a plain name like "k8s" finds the VM folder k8s
+func (m *sessionManager) GetFolder(ctx context.Context, dc *object.Datacenter, path string) (*object.Folder, error) {
+	finder, err := m.finder(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+	var folders []*object.Folder
+	var matches []string
+	if path != "" {
+		folders, err = finder.FolderList(ctx, path)
+	}
+	for _, o := range folders {
+		matches = append(matches, o.InventoryPath)
+	}
+	if err := single("folder", path, matches, err); err != nil {
+		return nil, err
+	}
+	return folders[0], nil
+}
+
+// GetResourcePool returns the resource pool in dc named path
+func (m *sessionManager) GetResourcePool(ctx context.Context, dc *object.Datacenter, path string) (*object.ResourcePool, error) {
+	finder, err := m.finder(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+	var pools []*object.ResourcePool
+	var matches []string
+	if path != "" {
+		pools, err = finder.ResourcePoolList(ctx, path)
+	}
+	for _, o := range pools {
+		matches = append(matches, o.InventoryPath)
+	}
+	if err := single("resource pool", path, matches, err); err != nil {
+		return nil, err
+	}
+	return pools[0], nil
+}
+
+// GetNetwork returns the network or distributed port group in dc named path
+func (m *sessionManager) GetNetwork(ctx context.Context, dc *object.Datacenter, path string) (object.NetworkReference, error) {
+	finder, err := m.finder(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+	var networks []object.NetworkReference
+	var matches []string
+	if path != "" {
+		networks, err = finder.NetworkList(ctx, path)
+	}
+	for _, o := range networks {
+		matches = append(matches, o.GetInventoryPath())
+	}
+	if err := single("network", path, matches, err); err != nil {
+		return nil, err
+	}
+	return networks[0], nil
+}
+
+// ResolveInfrastructure finds every object named in paths, each name or path has to match exactly one object
+func (m *sessionManager) ResolveInfrastructure(ctx context.Context, paths InventoryPaths) (*Infrastructure, error) {
+	var err error
+	infra := &Infrastructure{}
+	infra.Datacenter, err = m.GetDatacenter(ctx, paths.Datacenter)
+	if err != nil {
+		return nil, err
+	}
+	infra.Datastore, err = m.GetDatastore(ctx, infra.Datacenter, paths.Datastore)
+	if err != nil {
+		return nil, err
+	}
+	infra.Folder, err = m.GetFolder(ctx, infra.Datacenter, paths.Folder)
+	if err != nil {
+		return nil, err
+	}
+	infra.ResourcePool, err = m.GetResourcePool(ctx, infra.Datacenter, paths.ResourcePool)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths.Networks) == 0 {
+		return nil, fmt.Errorf("a network name or path is required")
+	}
+	for _, path := range paths.Networks {
+		network, err := m.GetNetwork(ctx, infra.Datacenter, path)
+		if err != nil {
+			return nil, err
+		}
+		infra.Networks = append(infra.Networks, network)
+	}
+	infra.Network = infra.Networks[0]
+	return infra, nil
+}
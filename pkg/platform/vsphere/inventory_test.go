@@ -0,0 +1,63 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveInfrastructure(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	_, err := r.Folder.CreateFolder(ctx, "k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	infra, err := r.SessionManager.ResolveInfrastructure(ctx, InventoryPaths{
+		Datacenter:   "DC0",
+		Datastore:    "LocalDS_0",
+		Folder:       "k8s",
+		ResourcePool: "/DC0/host/DC0_C0/Resources",
+		Networks:     []string{"VM Network", "DC0_DVPG0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if infra.Folder.InventoryPath != "/DC0/vm/k8s" || infra.ResourcePool.InventoryPath != "/DC0/host/DC0_C0/Resources" {
+		t.Fatalf("unexpected folder %v or resource pool %v", infra.Folder.InventoryPath, infra.ResourcePool.InventoryPath)
+	}
+	if len(infra.Networks) != 2 || infra.Network != infra.Networks[0] || infra.Networks[1].GetInventoryPath() != "/DC0/network/DC0_DVPG0" {
+		t.Fatalf("unexpected networks: %v", infra.Networks)
+	}
+}
+
+func TestResolveInfrastructureErrors(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	sm := r.SessionManager
+
+	_, err := sm.GetResourcePool(ctx, r.Datacenter, "*/Resources")
+	if e, ok := err.(*MultipleFoundError); !ok || len(e.Matches) != 2 {
+		t.Fatalf("expected a multiple found error, got %v", err)
+	}
+	_, err = sm.GetNetwork(ctx, r.Datacenter, "missing")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	_, err = sm.GetDatacenter(ctx, "missing")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	paths := InventoryPaths{Datacenter: "DC0", Datastore: "LocalDS_0", Folder: "vm", ResourcePool: "DC0_C0/Resources"}
+	if _, err := sm.ResolveInfrastructure(ctx, paths); err == nil {
+		t.Fatal("expected missing networks to fail")
+	}
+	paths.Networks = []string{"VM Network"}
+	paths.Datastore = ""
+	if _, err := sm.ResolveInfrastructure(ctx, paths); err == nil {
+		t.Fatal("expected a missing datastore to fail")
+	}
+}
@@ -0,0 +1,108 @@
+package vsphere
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// manifestLine matches an entry of an OVF manifest, e.g. "SHA256(disk.vmdk)= 2c26b4..."
+var manifestLine = regexp.MustCompile(`^(SHA1|SHA256)\((.+)\)\s*=\s*([0-9a-fA-F]+)$`)
+
+// manifestEntry is the expected digest of a file in the OVA
+type manifestEntry struct {
+	algorithm string
+	file      string
+	digest    string
+}
+
+// parseManifest reads the entries of an OVF manifest
+func parseManifest(b []byte) ([]manifestEntry, error) {
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		m := manifestLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("unsupported manifest entry %q, expected a SHA1 or SHA256 digest", line)
+		}
+		entries = append(entries, manifestEntry{algorithm: m[1], file: m[2], digest: strings.ToLower(m[3])})
+	}
+	return entries, scanner.Err()
+}
+
+// verifyManifest checks the files of a local OVA against the digests in its .mf manifest,
+// OVAs without a manifest are not checked
+func verifyManifest(ovaPath string) error {
+	f, err := os.Open(ovaPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var manifest []manifestEntry
+	var found bool
+	digests := map[string]map[string]string{}
+	tarReader := tar.NewReader(f)
+	for {
+		h, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Base(h.Name)
+		if path.Ext(name) == ".mf" {
+			b, err := ioutil.ReadAll(tarReader)
+			if err != nil {
+				return err
+			}
+			manifest, err = parseManifest(b)
+			if err != nil {
+				return fmt.Errorf("invalid manifest %s, %v", name, err)
+			}
+			found = true
+			continue
+		}
+		sha1Sum, sha256Sum := sha1.New(), sha256.New()
+		if _, err := io.Copy(io.MultiWriter(sha1Sum, sha256Sum), tarReader); err != nil {
+			return fmt.Errorf("unable to read %s, %v", name, err)
+		}
+		digests[name] = map[string]string{
+			"SHA1":   hex.EncodeToString(sha1Sum.Sum(nil)),
+			"SHA256": hex.EncodeToString(sha256Sum.Sum(nil)),
+		}
+	}
+	if !found {
+		log.Debugf("OVA %s has no manifest, not verifying checksums", ovaPath)
+		return nil
+	}
+
+	for _, e := range manifest {
+		d, ok := digests[e.file]
+		if !ok {
+			return fmt.Errorf("file %s in the manifest is missing from the OVA", e.file)
+		}
+		if d[e.algorithm] != e.digest {
+			return fmt.Errorf("%s checksum mismatch for %s, manifest has %s, got %s", e.algorithm, e.file, e.digest, d[e.algorithm])
+		}
+	}
+	log.Debugf("verified %d manifest checksums of OVA %s", len(manifest), ovaPath)
+	return nil
+}
@@ -0,0 +1,49 @@
+package vsphere
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// testManifest returns a manifest of the test OVA files, with SHA-256 for the descriptor and SHA-1 for the disk
+func testManifest() string {
+	files := testOVAFiles()
+	return fmt.Sprintf("SHA256(test.ovf)= %x\nSHA1(test-disk1.vmdk)= %x\n", sha256.Sum256(files["test.ovf"]), sha1.Sum(files["test-disk1.vmdk"]))
+}
+
+func TestVerifyManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		manifest string
+		valid    bool
+	}{
+		{"no manifest", "", true},
+		{"valid", testManifest(), true},
+		{"checksum mismatch", fmt.Sprintf("SHA256(test-disk1.vmdk)= %x\n", sha256.Sum256([]byte("other"))), false},
+		{"missing file", fmt.Sprintf("SHA256(test-disk2.vmdk)= %x\n", sha256.Sum256([]byte("other"))), false},
+		{"unsupported digest", "MD5(test.ovf)= d41d8cd98f00b204e9800998ecf8427e\n", false},
+	}
+	for _, tt := range tests {
+		var manifest []byte
+		if tt.manifest != "" {
+			manifest = []byte(tt.manifest)
+		}
+		err := verifyManifest(writeTestOVA(t, dir, manifest))
+		if tt.valid && err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 
 	pkgerrors "github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -20,15 +21,33 @@ import (
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/ovf"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/progress"
 	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
-// DeployOVATemplate uploads ova and makes it a template
-func (r *Resource) DeployOVATemplate(templateName, templatePath string) (*object.VirtualMachine, error) {
-	ctx := context.TODO()
+// OVAOptions controls DeployOVATemplate
+type OVAOptions struct {
+	// SHA256 is the expected digest of the OVA file, it is checked when set
+	SHA256 string
+	// CacheDir keeps downloaded OVAs, keyed by SHA256 when set or else by URL, so later imports
+	// do not download them again. Without it remote OVAs are downloaded to a temporary file.
+	CacheDir string
+	// Progress receives upload events for each file of the OVA, events are dropped while it is full
+	// so the upload never waits on the caller, buffer it to receive every one
+	Progress chan<- UploadEvent
+}
+
+// UploadEvent reports the upload progress of one file of an OVA
+type UploadEvent struct {
+	File       string
+	Percentage float32
+	Err        error
+}
 
-	vSphereClient, err := r.SessionManager.GetClient()
+// DeployOVATemplate uploads ova and makes it a template, the checksums in the OVA manifest are verified first
+func (r *Resource) DeployOVATemplate(ctx context.Context, templateName, templatePath string, opts OVAOptions) (*object.VirtualMachine, error) {
+	vSphereClient, err := r.SessionManager.GetClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get vSphere client, %v", err)
 	}
@@ -42,10 +61,14 @@ func (r *Resource) DeployOVATemplate(templateName, templatePath string) (*object
 		return foundTemplate, nil
 	}
 
+	attached := r.networks()
+	if len(attached) == 0 {
+		return nil, fmt.Errorf("a network is required to import the template")
+	}
 	networks := []types.OvfNetworkMapping{
 		{
 			Name:    "nic0",
-			Network: r.Network.Reference(),
+			Network: attached[0].Reference(),
 		},
 	}
 
@@ -63,7 +86,17 @@ func (r *Resource) DeployOVATemplate(templateName, templatePath string) (*object
 		NetworkMapping: networks,
 	}
 
-	vm, err := createVirtualMachine(ctx, cisp, templatePath, r)
+	ovaPath, cleanup, err := localOVA(ctx, vSphereClient, templatePath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get OVA %s, %v", templatePath, err)
+	}
+	defer cleanup()
+
+	if err := verifyManifest(ovaPath); err != nil {
+		return nil, fmt.Errorf("unable to verify OVA %s, %v", templatePath, err)
+	}
+
+	vm, err := createVirtualMachine(ctx, cisp, ovaPath, r, opts.Progress)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create virtual machine, %v", err)
 	}
@@ -81,8 +114,8 @@ func (r *Resource) DeployOVATemplate(templateName, templatePath string) (*object
 	return vm, nil
 }
 
-func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecParams, ovaPath string, vSphere *Resource) (*object.VirtualMachine, error) {
-	vSphereClient, err := vSphere.SessionManager.GetClient()
+func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecParams, ovaPath string, vSphere *Resource, events chan<- UploadEvent) (*object.VirtualMachine, error) {
+	vSphereClient, err := vSphere.SessionManager.GetClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get vSphere client, %v", err)
 	}
@@ -101,7 +134,7 @@ func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecPar
 	}
 	switch s := spec.ImportSpec.(type) {
 	case *types.VirtualMachineImportSpec:
-		if s.ConfigSpec.VAppConfig.GetVmConfigSpec().OvfSection != nil {
+		if s.ConfigSpec.VAppConfig != nil && s.ConfigSpec.VAppConfig.GetVmConfigSpec().OvfSection != nil {
 			s.ConfigSpec.VAppConfig.GetVmConfigSpec().OvfSection = nil
 		}
 	}
@@ -120,7 +153,7 @@ func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecPar
 	defer u.Done()
 
 	for _, i := range info.Items {
-		err = ovaClient.upload(ctx, lease, i, ovaPath)
+		err = ovaClient.upload(ctx, lease, i, ovaPath, events)
 		if err != nil {
 			return nil, fmt.Errorf("unable to import the template, %v", err)
 		}
@@ -169,7 +202,7 @@ func (t *tapeArchiveEntry) Close() error {
 }
 
 type ova interface {
-	upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, ovaPath string) error
+	upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, ovaPath string, events chan<- UploadEvent) error
 	getImportSpec(ctx context.Context, ovaPath string, resourcePool mo.Reference, datastore mo.Reference, cisp types.OvfCreateImportSpecParams) (*types.OvfCreateImportSpecResult, error)
 }
 
@@ -192,7 +225,7 @@ func newOVA(client *govmomi.Client, basePath string) (ova, error) {
 func (h *handler) getImportSpec(ctx context.Context, ovaPath string, resourcePool mo.Reference, datastore mo.Reference, cisp types.OvfCreateImportSpecParams) (*types.OvfCreateImportSpecResult, error) {
 	m := ovf.NewManager(h.client.Client)
 
-	o, err := h.readOvf("*.ovf", ovaPath)
+	o, err := h.readOvf(ctx, "*.ovf", ovaPath)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read OVF file from %s, %v", ovaPath, err)
 	}
@@ -200,10 +233,10 @@ func (h *handler) getImportSpec(ctx context.Context, ovaPath string, resourcePoo
 	return m.CreateImportSpec(ctx, string(o), resourcePool, datastore, cisp)
 }
 
-func (h *handler) upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, ovaPath string) error {
+func (h *handler) upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, ovaPath string, events chan<- UploadEvent) error {
 	file := item.Path
 
-	f, size, err := h.openOva(file, ovaPath)
+	f, size, err := h.openOva(ctx, file, ovaPath)
 	if err != nil {
 		return fmt.Errorf("unable to open OVA, %v", err)
 	}
@@ -212,12 +245,68 @@ func (h *handler) upload(ctx context.Context, lease *nfc.Lease, item nfc.FileIte
 	opts := soap.Upload{
 		ContentLength: size,
 	}
+	if events == nil {
+		return lease.Upload(ctx, item, f, opts)
+	}
 
-	return lease.Upload(ctx, item, f, opts)
+	// the lease still needs the reports of the item to update its progress
+	sink := &uploadSink{ctx: ctx, file: file, events: events}
+	opts.Progress = progress.Tee(item, sink)
+	err = lease.Upload(ctx, item, f, opts)
+	sink.wait()
+	return err
 }
 
-func (h *handler) readOvf(name string, ovaPath string) ([]byte, error) {
-	tarReader, _, err := h.openOva(name, ovaPath)
+// uploadSink turns the progress reports of a file upload into UploadEvents,
+// one per whole percent so large disks do not flood the caller
+type uploadSink struct {
+	// ctx is the upload context, the reports stop without closing the sink once it is done
+	ctx    context.Context
+	file   string
+	events chan<- UploadEvent
+	wg     sync.WaitGroup
+}
+
+func (s *uploadSink) Sink() chan<- progress.Report {
+	ch := make(chan progress.Report)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		last := float32(-1)
+		for {
+			var r progress.Report
+			var ok bool
+			select {
+			case r, ok = <-ch:
+			case <-s.ctx.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+			percentage := r.Percentage()
+			if r.Error() == nil && int(percentage) == int(last) {
+				continue
+			}
+			last = percentage
+			// the reports come from the upload itself, a caller that does not keep up must not stall it
+			select {
+			case s.events <- UploadEvent{File: s.file, Percentage: percentage, Err: r.Error()}:
+			default:
+				log.Debugf("dropped upload event of %v at %.0f%%, the progress channel is full", s.file, percentage)
+			}
+		}
+	}()
+	return ch
+}
+
+// wait returns once the reports end or the upload context is done
+func (s *uploadSink) wait() {
+	s.wg.Wait()
+}
+
+func (h *handler) readOvf(ctx context.Context, name string, ovaPath string) ([]byte, error) {
+	tarReader, _, err := h.openOva(ctx, name, ovaPath)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open OVA file %s, %v", ovaPath, err)
 	}
@@ -226,8 +315,8 @@ func (h *handler) readOvf(name string, ovaPath string) ([]byte, error) {
 	return ioutil.ReadAll(tarReader)
 }
 
-func (h *handler) openOva(name string, ovaPath string) (io.ReadCloser, int64, error) {
-	f, _, err := h.openFile(ovaPath)
+func (h *handler) openOva(ctx context.Context, name string, ovaPath string) (io.ReadCloser, int64, error) {
+	f, _, err := h.openFile(ctx, ovaPath)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -258,20 +347,20 @@ func (h *handler) openOva(name string, ovaPath string) (io.ReadCloser, int64, er
 	return nil, 0, os.ErrNotExist
 }
 
-func (h *handler) openFile(path string) (io.ReadCloser, int64, error) {
+func (h *handler) openFile(ctx context.Context, path string) (io.ReadCloser, int64, error) {
 	if isRemotePath(path) {
-		return h.openRemote(path)
+		return h.openRemote(ctx, path)
 	}
 	return openLocal(path)
 }
 
-func (h *handler) openRemote(link string) (io.ReadCloser, int64, error) {
+func (h *handler) openRemote(ctx context.Context, link string) (io.ReadCloser, int64, error) {
 	u, err := url.Parse(link)
 	if err != nil {
 		return nil, 0, fmt.Errorf("Error parsing url %s, %w", link, err)
 	}
 
-	return h.client.Client.Download(context.TODO(), u, &soap.DefaultDownload)
+	return h.client.Client.Download(ctx, u, &soap.DefaultDownload)
 
 }
 
@@ -279,7 +368,7 @@ func removeNICs(ctx context.Context, vm *object.VirtualMachine) error {
 
 	log.Debugf("Removing NICs from VM %s (%s)", vm.InventoryPath, vm.Reference())
 
-	vmProps, err := getProperties(vm)
+	vmProps, err := getProperties(ctx, vm)
 	if err != nil {
 		return pkgerrors.Wrap(err, "unable to get virtual machine properties")
 	}
@@ -320,5 +409,3 @@ func removeNICs(ctx context.Context, vm *object.VirtualMachine) error {
 
 	return nil
 }
-
-
@@ -1,39 +1,152 @@
 package vsphere
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
-func TestSetupTemplate(t *testing.T) {
-	vs := new(Resource)
-	c, err := NewManager("https://172.60.0.150", "administrator@vsphere.local", "NetApp1!!")
+func TestDeployOVATemplate(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "deploy_ova_test_")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	template, err := r.DeployOVATemplate(ctx, "test-template", writeTestOVA(t, dir, nil), OVAOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err := getProperties(ctx, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !props.Config.Template {
+		t.Fatal("expected the imported VM to be a template")
+	}
+	devices := object.VirtualDeviceList(props.Config.Hardware.Device)
+	if nics := devices.SelectByType((*types.VirtualEthernetCard)(nil)); len(nics) != 0 {
+		t.Fatalf("expected the template NICs to be removed, got %v", len(nics))
+	}
+	if disks := devices.SelectByType((*types.VirtualDisk)(nil)); len(disks) != 1 {
+		t.Fatalf("expected 1 disk, got %v", len(disks))
+	}
+
+	again, err := r.DeployOVATemplate(ctx, "test-template", "does-not-exist.ova", OVAOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Reference() != template.Reference() {
+		t.Fatal("expected the existing template to be returned")
+	}
+
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "from-ova"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := vm.PowerState(context.Background())
+	if err != nil || state != types.VirtualMachinePowerStatePoweredOn {
+		t.Fatalf("expected the clone to be powered on, got %v, %v", state, err)
 	}
+}
+
+func TestDeployOVATemplateMissing(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	_, err := r.DeployOVATemplate(ctx, "missing-template", "/does/not/exist.ova", OVAOptions{})
+	if err == nil {
+		t.Fatal("expected a missing OVA to fail")
+	}
+}
 
-	datacenters, _ := c.GetDatacenters()
+func TestDeployOVATemplateProgress(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "deploy_ova_test_")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
+	defer os.RemoveAll(dir)
+	ova := writeTestOVA(t, dir, []byte(testManifest()))
 
-	networks, _ := c.GetNetworks(datacenters[0])
-	datastores, _ := c.GetDatastores(datacenters[0])
-	folders, _ := c.GetFolders()
-	resourcepools, _ := c.GetResourcePools(datacenters[0])
+	// deploy fails the test when the upload waits on the progress channel
+	deploy := func(ctx context.Context, name string, events chan<- UploadEvent) error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := r.DeployOVATemplate(ctx, name, ova, OVAOptions{Progress: events})
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(30 * time.Second):
+			t.Fatalf("deploying %v stalled on the progress channel", name)
+		}
+		return nil
+	}
 
-	vs.Datacenter = datacenters[0]
-	vs.ResourcePool = resourcepools[0]
-	vs.Folder = folders[0]
-	vs.Datastore = datastores[0]
-	vs.Network = networks[0]
+	events := make(chan UploadEvent, 256)
+	err = deploy(ctx, "progress-template", events)
+	close(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []UploadEvent
+	for e := range events {
+		all = append(all, e)
+	}
+	if len(all) == 0 {
+		t.Fatal("expected upload events")
+	}
+	last := all[len(all)-1]
+	if last.File != "test-disk1.vmdk" || last.Percentage != 100 || last.Err != nil {
+		t.Fatalf("unexpected last event: %+v", last)
+	}
+
+	// nobody reads the events, they are dropped
+	err = deploy(ctx, "undrained-template", make(chan UploadEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	templateName := "ubuntu-1804-kube-v1.17.3"
-	templateOVA := "https://storage.googleapis.com/capv-images/release/v1.17.3/ubuntu-1804-kube-v1.17.3.ova"
+	// the caller cancels on the first event and stops reading
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cancelled := make(chan UploadEvent)
+	go func() {
+		<-cancelled
+		cancel()
+	}()
+	_ = deploy(cancelCtx, "cancelled-template", cancelled)
+}
 
-	vs.SessionManager = c
-	_, err = vs.DeployOVATemplate(templateName, templateOVA)
+func TestDeployOVATemplateBadManifest(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	dir, err := ioutil.TempDir("", "deploy_ova_test_")
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
+	defer os.RemoveAll(dir)
 
+	manifest := []byte("SHA1(test-disk1.vmdk)= 0000000000000000000000000000000000000000\n")
+	_, err = r.DeployOVATemplate(ctx, "bad-template", writeTestOVA(t, dir, manifest), OVAOptions{})
+	if err == nil {
+		t.Fatal("expected a checksum mismatch to fail")
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "bad-template"); err == nil {
+		t.Fatal("expected nothing to be imported")
+	}
 }
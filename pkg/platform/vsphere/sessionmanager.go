@@ -11,17 +11,26 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
 )
 
 // SessionManager manages vSphere client sessions
 type SessionManager interface {
-	GetClient() (*govmomi.Client, error)
-	GetDatacenters() ([]*object.Datacenter, error)
-	GetNetworks(*object.Datacenter) ([]object.NetworkReference, error)
-	GetFolders() ([]*object.Folder, error)
-	GetDatastores(*object.Datacenter) ([]*object.Datastore, error)
-	GetResourcePools(*object.Datacenter) ([]*object.ResourcePool, error)
-	GetVM(dc *object.Datacenter, name string) (*object.VirtualMachine, error)
+	GetClient(ctx context.Context) (*govmomi.Client, error)
+	GetDatacenters(ctx context.Context) ([]*object.Datacenter, error)
+	GetNetworks(ctx context.Context, dc *object.Datacenter) ([]object.NetworkReference, error)
+	GetFolders(ctx context.Context) ([]*object.Folder, error)
+	GetDatastores(ctx context.Context, dc *object.Datacenter) ([]*object.Datastore, error)
+	GetResourcePools(ctx context.Context, dc *object.Datacenter) ([]*object.ResourcePool, error)
+	GetVM(ctx context.Context, dc *object.Datacenter, name string) (*object.VirtualMachine, error)
+	GetDatacenter(ctx context.Context, name string) (*object.Datacenter, error)
+	GetDatastore(ctx context.Context, dc *object.Datacenter, path string) (*object.Datastore, error)
+	GetFolder(ctx context.Context, dc *object.Datacenter, path string) (*object.Folder, error)
+	GetResourcePool(ctx context.Context, dc *object.Datacenter, path string) (*object.ResourcePool, error)
+	GetNetwork(ctx context.Context, dc *object.Datacenter, path string) (object.NetworkReference, error)
+	ResolveInfrastructure(ctx context.Context, paths InventoryPaths) (*Infrastructure, error)
 }
 
 type sessionManager struct {
@@ -29,19 +38,26 @@ type sessionManager struct {
 	server   string
 	username string
 	password string
+	trust    trust
 }
 
-// NewManager returns a new SessionManager
-func NewManager(server string, username string, password string) (SessionManager, error) {
+// NewManager returns a new SessionManager, opts set how the vCenter certificate is verified
+func NewManager(ctx context.Context, server string, username string, password string, opts ...ManagerOption) (SessionManager, error) {
 
 	sm := sessionManager{
 		server:   server,
 		username: username,
 		password: password,
 	}
+	for _, opt := range opts {
+		opt(&sm.trust)
+	}
+	if err := sm.trust.validate(); err != nil {
+		return nil, err
+	}
 
 	// Verify connection
-	_, err := sm.GetClient()
+	_, err := sm.GetClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("unable to verify connection, %v", err)
 	}
@@ -50,9 +66,7 @@ func NewManager(server string, username string, password string) (SessionManager
 }
 
 // GetClient returns a govmomi client with an active session
-func (m *sessionManager) GetClient() (*govmomi.Client, error) {
-
-	ctx := context.TODO()
+func (m *sessionManager) GetClient(ctx context.Context) (*govmomi.Client, error) {
 
 	if m.client != nil {
 		sessionActive, err := m.client.SessionManager.SessionIsActive(ctx)
@@ -80,10 +94,18 @@ func (m *sessionManager) GetClient() (*govmomi.Client, error) {
 
 	authenticatedURL.User = url.UserPassword(m.username, m.password)
 
-	client, err := govmomi.NewClient(ctx, nonAuthURL, true)
+	soapClient := soap.NewClient(nonAuthURL, m.trust.insecure)
+	if err = m.trust.configure(soapClient, nonAuthURL); err != nil {
+		return nil, fmt.Errorf("unable to verify vCenter certificate, %v", err)
+	}
+	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create new vSphere client, %v", err)
 	}
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
 
 	if err = client.Login(ctx, authenticatedURL.User); err != nil {
 		return nil, fmt.Errorf("unable to login to vSphere, %v", err)
@@ -95,15 +117,15 @@ func (m *sessionManager) GetClient() (*govmomi.Client, error) {
 
 }
 
-func (m *sessionManager) GetDatacenters() ([]*object.Datacenter, error) {
+func (m *sessionManager) GetDatacenters(ctx context.Context) ([]*object.Datacenter, error) {
 	var err error
 
-	client, err := m.GetClient()
+	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	finder := find.NewFinder(client.Client, true)
-	datacenters, err := finder.DatacenterList(context.TODO(), "*")
+	datacenters, err := finder.DatacenterList(ctx, "*")
 	if err != nil {
 		return nil, err
 	}
@@ -111,16 +133,16 @@ func (m *sessionManager) GetDatacenters() ([]*object.Datacenter, error) {
 
 }
 
-func (m *sessionManager) GetDatastores(dc *object.Datacenter) ([]*object.Datastore, error) {
+func (m *sessionManager) GetDatastores(ctx context.Context, dc *object.Datacenter) ([]*object.Datastore, error) {
 	var err error
 
-	client, err := m.GetClient()
+	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	finder := find.NewFinder(client.Client, true)
 	finder.SetDatacenter(dc)
-	datastores, err := finder.DatastoreList(context.TODO(), "*")
+	datastores, err := finder.DatastoreList(ctx, "*")
 	if err != nil {
 		return nil, err
 	}
@@ -128,16 +150,16 @@ func (m *sessionManager) GetDatastores(dc *object.Datacenter) ([]*object.Datasto
 
 }
 
-func (m *sessionManager) GetNetworks(dc *object.Datacenter) ([]object.NetworkReference, error) {
+func (m *sessionManager) GetNetworks(ctx context.Context, dc *object.Datacenter) ([]object.NetworkReference, error) {
 	var err error
 
-	client, err := m.GetClient()
+	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	finder := find.NewFinder(client.Client, true)
 	finder.SetDatacenter(dc)
-	networks, err := finder.NetworkList(context.TODO(), "*")
+	networks, err := finder.NetworkList(ctx, "*")
 	if err != nil {
 		return nil, err
 	}
@@ -145,15 +167,15 @@ func (m *sessionManager) GetNetworks(dc *object.Datacenter) ([]object.NetworkRef
 
 }
 
-func (m *sessionManager) GetFolders() ([]*object.Folder, error) {
+func (m *sessionManager) GetFolders(ctx context.Context) ([]*object.Folder, error) {
 	var err error
 
-	client, err := m.GetClient()
+	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	finder := find.NewFinder(client.Client, true)
-	folders, err := finder.FolderList(context.TODO(), "*")
+	folders, err := finder.FolderList(ctx, "*")
 	if err != nil {
 		return nil, err
 	}
@@ -161,16 +183,16 @@ func (m *sessionManager) GetFolders() ([]*object.Folder, error) {
 
 }
 
-func (m *sessionManager) GetResourcePools(dc *object.Datacenter) ([]*object.ResourcePool, error) {
+func (m *sessionManager) GetResourcePools(ctx context.Context, dc *object.Datacenter) ([]*object.ResourcePool, error) {
 	var err error
 
-	client, err := m.GetClient()
+	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	finder := find.NewFinder(client.Client, true)
 	finder.SetDatacenter(dc)
-	folders, err := finder.ResourcePoolList(context.TODO(), "*")
+	folders, err := finder.ResourcePoolList(ctx, "*")
 	if err != nil {
 		return nil, err
 	}
@@ -178,14 +200,14 @@ func (m *sessionManager) GetResourcePools(dc *object.Datacenter) ([]*object.Reso
 
 }
 
-func (m *sessionManager) GetVM(dc *object.Datacenter, name string) (*object.VirtualMachine, error) {
-	client, err := m.GetClient()
+func (m *sessionManager) GetVM(ctx context.Context, dc *object.Datacenter, name string) (*object.VirtualMachine, error) {
+	client, err := m.GetClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 	finder := find.NewFinder(client.Client, true)
 	finder.SetDatacenter(dc)
-	vm, err := finder.VirtualMachine(context.TODO(), name)
+	vm, err := finder.VirtualMachine(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,89 @@
+package vsphere
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+)
+
+func TestSessionManager(t *testing.T) {
+	ctx := context.Background()
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+	sm := r.SessionManager
+
+	client, err := sm.GetClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := sm.GetClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != again {
+		t.Fatal("expected the active session to be reused")
+	}
+
+	datacenters, err := sm.GetDatacenters(ctx)
+	if err != nil || len(datacenters) != 1 {
+		t.Fatalf("expected 1 datacenter, got %v, %v", len(datacenters), err)
+	}
+	datastores, err := sm.GetDatastores(ctx, datacenters[0])
+	if err != nil || len(datastores) == 0 {
+		t.Fatalf("expected datastores, got %v, %v", len(datastores), err)
+	}
+	networks, err := sm.GetNetworks(ctx, datacenters[0])
+	if err != nil || len(networks) == 0 {
+		t.Fatalf("expected networks, got %v, %v", len(networks), err)
+	}
+	folders, err := sm.GetFolders(ctx)
+	if err != nil || len(folders) == 0 {
+		t.Fatalf("expected folders, got %v, %v", len(folders), err)
+	}
+	pools, err := sm.GetResourcePools(ctx, datacenters[0])
+	if err != nil || len(pools) == 0 {
+		t.Fatalf("expected resource pools, got %v, %v", len(pools), err)
+	}
+	vm, err := sm.GetVM(ctx, datacenters[0], "DC0_H0_VM0")
+	if err != nil || vm == nil {
+		t.Fatalf("expected to find DC0_H0_VM0, %v", err)
+	}
+	if _, err := sm.GetVM(ctx, datacenters[0], "missing"); err == nil {
+		t.Fatal("expected a missing VM to fail")
+	}
+}
+
+func TestNewManagerInvalidLogin(t *testing.T) {
+	ctx := context.Background()
+	model := simulator.VPX()
+	err := model.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	model.Service.Listen = &url.URL{User: url.UserPassword("user", "pass")}
+	s := model.Service.NewServer()
+	defer s.Close()
+
+	server := url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host, Path: s.URL.Path}
+	_, err = NewManager(ctx, server.String(), "user", "wrong")
+	if err == nil {
+		t.Fatal("expected an invalid login to fail")
+	}
+}
+
+func TestSessionManagerCancelled(t *testing.T) {
+	r, _, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.SessionManager.GetDatacenters(ctx); err == nil {
+		t.Fatal("expected a cancelled listing to fail")
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "DC0_H0_VM0"); err == nil {
+		t.Fatal("expected a cancelled lookup to fail")
+	}
+}
@@ -0,0 +1,177 @@
+package vsphere
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+)
+
+// newSimulatorResource starts a vcsim vCenter and returns a Resource on its inventory and a VM to clone
+func newSimulatorResource(t *testing.T) (*Resource, *object.VirtualMachine, func()) {
+	return newDelayedSimulatorResource(t, simulator.DelayConfig{})
+}
+
+// newDelayedSimulatorResource is newSimulatorResource with vcsim delaying its calls by delay
+func newDelayedSimulatorResource(t *testing.T, delay simulator.DelayConfig) (*Resource, *object.VirtualMachine, func()) {
+	model := simulator.VPX()
+	model.DelayConfig = delay
+	err := model.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the NFC lease URLs for OVA uploads are always https
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	cleanup := func() {
+		s.Close()
+		model.Remove()
+	}
+
+	ctx := context.Background()
+	password, _ := s.URL.User.Password()
+	server := url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host, Path: s.URL.Path}
+	caBundle, err := s.CertificateFile()
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	sm, err := NewManager(ctx, server.String(), s.URL.User.Username(), password, WithCABundle(caBundle))
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	infra, err := sm.ResolveInfrastructure(ctx, InventoryPaths{
+		Datacenter:   "DC0",
+		Datastore:    "LocalDS_0",
+		Folder:       "vm",
+		ResourcePool: "DC0_C0/Resources",
+		Networks:     []string{"VM Network"},
+	})
+	var template *object.VirtualMachine
+	if err == nil {
+		template, err = sm.GetVM(ctx, infra.Datacenter, "DC0_C0_RP0_VM0")
+	}
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	r := &Resource{Infrastructure: *infra, SessionManager: sm}
+	return r, template, cleanup
+}
+
+// testOVF is a minimal descriptor with one disk and one NIC mapped to nic0, %d is the disk file size
+const testOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References>
+    <File ovf:href="test-disk1.vmdk" ovf:id="file1" ovf:size="%d"/>
+  </References>
+  <DiskSection>
+    <Info>Virtual disks</Info>
+    <Disk ovf:capacity="1" ovf:capacityAllocationUnits="byte * 2^30" ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+  </DiskSection>
+  <NetworkSection>
+    <Info>Networks</Info>
+    <Network ovf:name="nic0">
+      <Description>nic0</Description>
+    </Network>
+  </NetworkSection>
+  <VirtualSystem ovf:id="test">
+    <Info>A test VM</Info>
+    <Name>test</Name>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:ElementName>1 virtual CPU</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>1</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:ElementName>512MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>512</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:ElementName>SCSI Controller 0</rasd:ElementName>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceSubType>lsilogic</rasd:ResourceSubType>
+        <rasd:ResourceType>6</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>0</rasd:AddressOnParent>
+        <rasd:ElementName>Hard Disk 1</rasd:ElementName>
+        <rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource>
+        <rasd:InstanceID>4</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Connection>nic0</rasd:Connection>
+        <rasd:ElementName>Network adapter 1</rasd:ElementName>
+        <rasd:InstanceID>5</rasd:InstanceID>
+        <rasd:ResourceSubType>VmxNet3</rasd:ResourceSubType>
+        <rasd:ResourceType>10</rasd:ResourceType>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`
+
+// testOVAFiles are the files of the OVA written by writeTestOVA
+func testOVAFiles() map[string][]byte {
+	disk := []byte("not really a stream optimized disk")
+	return map[string][]byte{
+		"test.ovf":        []byte(fmt.Sprintf(testOVF, len(disk))),
+		"test-disk1.vmdk": disk,
+	}
+}
+
+// writeTestOVA writes a small OVA to dir and returns its path, the manifest follows the descriptor when set
+func writeTestOVA(t *testing.T, dir string, manifest []byte) string {
+	location := filepath.Join(dir, "test.ova")
+	f, err := os.Create(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	files := testOVAFiles()
+	names := []string{"test.ovf", "test-disk1.vmdk"}
+	if manifest != nil {
+		files["test.mf"] = manifest
+		names = []string{"test.ovf", "test.mf", "test-disk1.vmdk"}
+	}
+	w := tar.NewWriter(f)
+	// the descriptor must come first in an OVA
+	for _, name := range names {
+		err = w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err == nil {
+			_, err = w.Write(files[name])
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
@@ -15,8 +15,7 @@ import (
 	vim25types "github.com/vmware/govmomi/vim25/types"
 )
 
-func getProperties(vm *object.VirtualMachine) (*mo.VirtualMachine, error) {
-	ctx := context.TODO()
+func getProperties(ctx context.Context, vm *object.VirtualMachine) (*mo.VirtualMachine, error) {
 	var props mo.VirtualMachine
 	if err := vm.Properties(ctx, vm.Reference(), nil, &props); err != nil {
 		return nil, fmt.Errorf("unable to get virtual machine properties, %v", err)
@@ -24,8 +23,7 @@ func getProperties(vm *object.VirtualMachine) (*mo.VirtualMachine, error) {
 	return &props, nil
 }
 
-func vmExists(vm *object.VirtualMachine) (bool, error) {
-	ctx := context.TODO()
+func vmExists(ctx context.Context, vm *object.VirtualMachine) (bool, error) {
 	foundVM, err := find.NewFinder(vm.Client(), true).VirtualMachine(ctx, vm.InventoryPath)
 	if err != nil {
 		if _, ok := err.(*find.NotFoundError); ok {
@@ -41,9 +39,7 @@ func vmExists(vm *object.VirtualMachine) (bool, error) {
 	return true, nil
 }
 
-func getTasksForVM(vm *object.VirtualMachine) ([]vim25types.TaskInfo, error) {
-
-	ctx := context.TODO()
+func getTasksForVM(ctx context.Context, vm *object.VirtualMachine) ([]vim25types.TaskInfo, error) {
 
 	moRef := vm.Reference()
 	taskView, err := view.NewManager(vm.Client()).CreateTaskView(ctx, &moRef)
@@ -62,9 +58,7 @@ func getTasksForVM(vm *object.VirtualMachine) ([]vim25types.TaskInfo, error) {
 	return vmTasks, nil
 }
 
-func cancelRunningTasks(client *vim25.Client, taskInfos []vim25types.TaskInfo) error {
-
-	ctx := context.TODO()
+func cancelRunningTasks(ctx context.Context, client *vim25.Client, taskInfos []vim25types.TaskInfo) error {
 
 	for _, taskInfo := range taskInfos {
 
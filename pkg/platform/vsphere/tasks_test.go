@@ -0,0 +1,101 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// cancelableTask is a vcsim task that stays queued until it is cancelled, vcsim tasks do not implement CancelTask
+type cancelableTask struct {
+	simulator.Task
+}
+
+func (t *cancelableTask) CancelTask(req *types.CancelTask) soap.HasFault {
+	simulator.Map.Update(t, []types.PropertyChange{
+		{Name: "info.state", Val: types.TaskInfoStateError},
+		{Name: "info.cancelled", Val: true},
+	})
+	return &methods.CancelTaskBody{Res: new(types.CancelTaskResponse)}
+}
+
+// queueTask adds a queued task on the VM that DeleteVM has to cancel
+func queueTask(vm *ClonedVM, name string) *cancelableTask {
+	obj := simulator.Map.Get(vm.Reference()).(*simulator.VirtualMachine)
+	task := &cancelableTask{Task: *simulator.CreateTask(obj, name, nil)}
+	simulator.Map.Put(task)
+	simulator.Map.WithLock(obj, func() {
+		simulator.Map.Update(obj, []types.PropertyChange{
+			{Name: "recentTask", Val: append(obj.RecentTask, task.Self)},
+		})
+	})
+	return task
+}
+
+func TestDeleteVM(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "delete-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = DeleteVM(ctx, vm.VirtualMachine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "delete-0"); err == nil {
+		t.Fatal("expected the VM to be deleted")
+	}
+	err = DeleteVM(ctx, vm.VirtualMachine)
+	if err != nil {
+		t.Fatalf("expected deleting a missing VM to succeed, %v", err)
+	}
+}
+
+func TestDeleteVMCancelsTasks(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "delete-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := queueTask(vm, "reconfigVMTask")
+
+	err = DeleteVM(ctx, vm.VirtualMachine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.Info.Cancelled {
+		t.Fatal("expected the queued task to be cancelled")
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "delete-1"); err == nil {
+		t.Fatal("expected the VM to be deleted")
+	}
+}
+
+func TestHasCreationTask(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []types.TaskInfo
+		want  bool
+	}{
+		{"none", nil, false},
+		{"running clone", []types.TaskInfo{{DescriptionId: "VirtualMachine.clone", State: types.TaskInfoStateRunning}}, true},
+		{"queued upload", []types.TaskInfo{{DescriptionId: "ResourcePool.ImportVAppLRO", State: types.TaskInfoStateQueued}}, true},
+		{"finished clone", []types.TaskInfo{{DescriptionId: "VirtualMachine.clone", State: types.TaskInfoStateSuccess}}, false},
+		{"running power on", []types.TaskInfo{{DescriptionId: "VirtualMachine.powerOn", State: types.TaskInfoStateRunning}}, false},
+	}
+	for _, tt := range tests {
+		if got := hasCreationTask(tt.tasks); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
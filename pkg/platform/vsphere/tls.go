@@ -0,0 +1,177 @@
+package vsphere
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// systemCertPool returns the system CAs, the CA bundle is added to them
+var systemCertPool = x509.SystemCertPool
+
+// ManagerOption sets how a SessionManager verifies the vCenter certificate,
+// without options the certificate has to be signed by a CA in the system pool
+type ManagerOption func(*trust)
+
+// trust holds the certificate verification settings of a SessionManager
+type trust struct {
+	caBundle   string
+	thumbprint string
+	insecure   bool
+}
+
+// WithCABundle trusts the CA certificates in the PEM file at path
+func WithCABundle(path string) ManagerOption {
+	return func(t *trust) {
+		t.caBundle = path
+	}
+}
+
+// WithThumbprint pins the vCenter certificate to its SHA-1 or SHA-256 thumbprint,
+// the thumbprint is hex with or without colons
+func WithThumbprint(thumbprint string) ManagerOption {
+	return func(t *trust) {
+		t.thumbprint = thumbprint
+	}
+}
+
+// WithInsecure skips verification of the vCenter certificate
+func WithInsecure() ManagerOption {
+	return func(t *trust) {
+		t.insecure = true
+	}
+}
+
+// parseThumbprint decodes a SHA-1 or SHA-256 certificate thumbprint
+func parseThumbprint(thumbprint string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.NewReplacer(":", "", " ", "").Replace(thumbprint))
+	if err != nil {
+		return nil, fmt.Errorf("invalid thumbprint %v, %v", thumbprint, err)
+	}
+	if len(b) != sha1.Size && len(b) != sha256.Size {
+		return nil, fmt.Errorf("invalid thumbprint %v, expected a SHA-1 or SHA-256 checksum", thumbprint)
+	}
+	return b, nil
+}
+
+// formatThumbprint returns a thumbprint the way vSphere writes them, colon separated upper case hex
+func formatThumbprint(b []byte) string {
+	hex := make([]string, len(b))
+	for i, c := range b {
+		hex[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(hex, ":")
+}
+
+func (t trust) validate() error {
+	if t.insecure && (t.caBundle != "" || t.thumbprint != "") {
+		return fmt.Errorf("an insecure connection cannot also verify a CA bundle or thumbprint")
+	}
+	if t.thumbprint != "" {
+		_, err := parseThumbprint(t.thumbprint)
+		return err
+	}
+	return nil
+}
+
+// configure applies the trust settings to the SOAP client of server
+func (t trust) configure(c *soap.Client, server *url.URL) error {
+	if t.insecure {
+		log.Warnf("Not verifying the certificate of vCenter %s", server.Host)
+		return nil
+	}
+	transport, ok := c.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("unexpected vSphere client transport %T", c.Transport)
+	}
+	if t.caBundle != "" {
+		pool, err := rootCAs(t.caBundle)
+		if err != nil {
+			return fmt.Errorf("unable to load CA bundle %v, %v", t.caBundle, err)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if t.thumbprint != "" {
+		expected, err := parseThumbprint(t.thumbprint)
+		if err != nil {
+			return err
+		}
+		c.SetThumbprint(hostPort(server), formatThumbprint(expected))
+	}
+	transport.DialTLS = dialTLS(c, transport.TLSClientConfig)
+	return nil
+}
+
+// rootCAs adds the CAs in the PEM files of the bundle, a path list, to the system CAs. The client also
+// downloads remote OVAs, which are usually signed by a public CA rather than the vCenter CA.
+func rootCAs(bundle string) (*x509.CertPool, error) {
+	pool, err := systemCertPool()
+	if err != nil || pool == nil {
+		log.Debugf("unable to load the system CAs, only trusting the CA bundle, %v", err)
+		pool = x509.NewCertPool()
+	}
+	for _, name := range filepath.SplitList(bundle) {
+		pem, err := ioutil.ReadFile(filepath.Clean(name))
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", name)
+		}
+	}
+	return pool, nil
+}
+
+// dialTLS pins the hosts the client has a thumbprint for, the vCenter and the hosts of NFC leases,
+// and verifies other hosts against the CA pool of config
+func dialTLS(c *soap.Client, config *tls.Config) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		pinned := c.Thumbprint(addr)
+		if pinned == "" {
+			return tls.Dial(network, addr, config)
+		}
+		expected, err := parseThumbprint(pinned)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := tls.Dial(network, addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return nil, err
+		}
+		cert := conn.ConnectionState().PeerCertificates[0]
+		var actual []byte
+		if len(expected) == sha1.Size {
+			sum := sha1.Sum(cert.Raw)
+			actual = sum[:]
+		} else {
+			sum := sha256.Sum256(cert.Raw)
+			actual = sum[:]
+		}
+		if !bytes.Equal(actual, expected) {
+			_ = conn.Close()
+			return nil, fmt.Errorf("host %v thumbprint %v does not match %v", addr, formatThumbprint(actual), pinned)
+		}
+		return conn, nil
+	}
+}
+
+// hostPort returns the host of u with the https port when it has none
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), "443")
+}
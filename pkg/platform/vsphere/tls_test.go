@@ -0,0 +1,54 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+func TestNewManagerTLS(t *testing.T) {
+	ctx := context.Background()
+	model := simulator.VPX()
+	err := model.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	defer s.Close()
+
+	password, _ := s.URL.User.Password()
+	server := url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host, Path: s.URL.Path}
+	sha256Sum := sha256.Sum256(s.Certificate().Raw)
+
+	tests := []struct {
+		name  string
+		opts  []ManagerOption
+		valid bool
+	}{
+		{"system CAs", nil, false},
+		{"insecure", []ManagerOption{WithInsecure()}, true},
+		{"SHA-1 thumbprint", []ManagerOption{WithThumbprint(soap.ThumbprintSHA1(s.Certificate()))}, true},
+		{"SHA-256 thumbprint", []ManagerOption{WithThumbprint(hex.EncodeToString(sha256Sum[:]))}, true},
+		{"wrong thumbprint", []ManagerOption{WithThumbprint(formatThumbprint(make([]byte, sha256.Size)))}, false},
+		{"invalid thumbprint", []ManagerOption{WithThumbprint("AB:CD")}, false},
+		{"insecure and thumbprint", []ManagerOption{WithInsecure(), WithThumbprint(soap.ThumbprintSHA1(s.Certificate()))}, false},
+		{"missing CA bundle", []ManagerOption{WithCABundle("/missing/ca.pem")}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewManager(ctx, server.String(), s.URL.User.Username(), password, tt.opts...)
+		if tt.valid && err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%v: expected an error", tt.name)
+		}
+	}
+}
@@ -17,4 +17,41 @@ type Infrastructure struct {
 	Folder       *object.Folder
 	ResourcePool *object.ResourcePool
 	Network      object.NetworkReference
+	// Networks attaches one NIC per network to clones, in order, instead of Network
+	Networks []object.NetworkReference
+}
+
+// networks returns the networks clones are attached to
+func (i *Infrastructure) networks() []object.NetworkReference {
+	if len(i.Networks) > 0 {
+		return i.Networks
+	}
+	if i.Network != nil {
+		return []object.NetworkReference{i.Network}
+	}
+	return nil
+}
+
+// ClonedVM is a VM cloned from a template
+type ClonedVM struct {
+	*object.VirtualMachine
+	// NICs are in the order of the Infrastructure networks
+	NICs []NIC
+}
+
+// NIC is a network interface of a cloned VM
+type NIC struct {
+	MACAddress string
+	Network    object.NetworkReference
+	// IPAddresses reported by the guest, set when waiting for the guest
+	IPAddresses []string
+}
+
+// NetworksByMAC maps the MAC address of each NIC to its network
+func (c *ClonedVM) NetworksByMAC() map[string]object.NetworkReference {
+	result := map[string]object.NetworkReference{}
+	for _, n := range c.NICs {
+		result[n.MACAddress] = n.Network
+	}
+	return result
 }
@@ -3,22 +3,51 @@ package vsphere
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
+	configtypes "github.com/netapp/cake/pkg/config/types"
 	"github.com/netapp/cake/pkg/platform/vsphere/cloudinit"
 	log "github.com/sirupsen/logrus"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
-func (r *Resource) CloneTemplate(template *object.VirtualMachine, name string, bootScript, publicKey, osUser string) (*object.VirtualMachine, error) {
-
-	// give whole clone process a 10 minute timeout
-	d := time.Now().Add(10 * time.Minute)
-	ctx, cancel := context.WithDeadline(context.Background(), d)
-	defer cancel()
+// CloneTemplate clones the template to a new VM with one NIC per network of the Resource and powers it on.
+// With spec.Wait set it also waits for the guest to report an address on every NIC.
+// The VM is deleted when a step after the clone fails, e.g. the power on or the wait.
+func (r *Resource) CloneTemplate(ctx context.Context, template *object.VirtualMachine, spec CloneSpec) (*ClonedVM, error) {
+	vm, err := r.cloneTemplate(ctx, ctx, template, spec)
+	if err != nil {
+		if vm != nil {
+			log.Debugf("deleting VM %s after the failed clone", spec.Name)
+			if derr := DeleteVM(ctx, vm.VirtualMachine); derr != nil {
+				return nil, fmt.Errorf("%v, unable to delete VM %v, %v", err, spec.Name, derr)
+			}
+		}
+		return nil, err
+	}
+	return vm, nil
+}
 
-	cloudinitUserDataConfig, err := cloudinit.GenerateUserData(bootScript, publicKey, osUser)
+// cloneTemplate returns the new VM along with any error after the clone task succeeded,
+// so callers can clean it up. The clone task is created and waited for with cloneCtx, and the VM found
+// with it, so a VM vSphere creates is returned even when ctx is done while the task runs.
+func (r *Resource) cloneTemplate(ctx, cloneCtx context.Context, template *object.VirtualMachine, cs CloneSpec) (*ClonedVM, error) {
+	name := cs.Name
+	for i, n := range cs.Networks {
+		if err := n.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid network %v, %v", i, err)
+		}
+	}
+	networks := r.networks()
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no networks to attach")
+	}
+	if len(cs.Networks) > 0 && len(cs.Networks) != len(networks) {
+		return nil, fmt.Errorf("got %v network configs for %v networks", len(cs.Networks), len(networks))
+	}
+	cloudinitUserDataConfig, err := cloudinit.GenerateUserData(cs.BootScript, cs.PublicKey, cs.OSUser)
 	if err != nil {
 		return nil, fmt.Errorf("unable to generate user data, %v", err)
 	}
@@ -32,14 +61,17 @@ func (r *Resource) CloneTemplate(template *object.VirtualMachine, name string, b
 	spec.PowerOn = false // Do not turn machine on until after metadata reconfiguration
 	spec.Location.DiskMoveType = string(types.VirtualMachineRelocateDiskMoveOptionsMoveAllDiskBackingsAndConsolidate)
 
-	vmProps, err := getProperties(template)
+	vmProps, err := getProperties(ctx, template)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get virtual machine properties, %v", err)
 	}
 
 	l := object.VirtualDeviceList(vmProps.Config.Hardware.Device)
 
-	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+	deviceSpecs, err := sizeConfigSpec(spec.Config, l, cs.Size)
+	if err != nil {
+		return nil, err
+	}
 
 	nics := l.SelectByType((*types.VirtualEthernetCard)(nil))
 
@@ -52,58 +84,174 @@ func (r *Resource) CloneTemplate(template *object.VirtualMachine, name string, b
 		deviceSpecs = append(deviceSpecs, nicspec)
 	}
 
-	nic := types.VirtualVmxnet3{}
-	nic.Backing, err = r.Network.EthernetCardBackingInfo(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get information on NIC, %v", err)
+	// negative keys keep the NICs in the order of the networks when vSphere assigns the real keys
+	for i, network := range networks {
+		nic := types.VirtualVmxnet3{}
+		nic.Key = int32(-100 - i)
+		nic.Backing, err = network.EthernetCardBackingInfo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get information on NIC, %v", err)
+		}
+		nicspec := &types.VirtualDeviceConfigSpec{}
+		nicspec.Operation = types.VirtualDeviceConfigSpecOperationAdd
+		nicspec.Device = &nic
+		deviceSpecs = append(deviceSpecs, nicspec)
 	}
-	nicspec := &types.VirtualDeviceConfigSpec{}
-	nicspec.Operation = types.VirtualDeviceConfigSpecOperationAdd
-	nicspec.Device = &nic
-	deviceSpecs = append(deviceSpecs, nicspec)
 
 	spec.Config.DeviceChange = deviceSpecs
 
 	log.Debugf("cloning %s with spec: %+v", name, spec)
-	task, err := template.Clone(ctx, r.Folder, name, spec)
+	task, err := template.Clone(cloneCtx, r.Folder, name, spec)
 	if err != nil {
 		return nil, fmt.Errorf("unable to clone template, %v", err)
 	}
 
-	err = task.Wait(ctx)
+	err = task.Wait(cloneCtx)
 	if err != nil {
 		return nil, fmt.Errorf("clone task failed, %v", err)
 	}
 
-	vm, err := r.SessionManager.GetVM(r.Datacenter, name)
+	vm, err := r.SessionManager.GetVM(cloneCtx, r.Datacenter, name)
 	if err != nil {
 		return nil, fmt.Errorf("unable to find virtual machine, %v", err)
 	}
+	clone := &ClonedVM{VirtualMachine: vm}
 
-	err = task.Wait(ctx)
+	clone.NICs, err = clonedNICs(ctx, vm, networks)
 	if err != nil {
-		return nil, fmt.Errorf("reconfigure task failed, %v", err)
+		return clone, err
+	}
+
+	if len(cs.Networks) > 0 {
+		err = setNetworkMetadata(ctx, vm, name, clone.NICs, cs.Networks)
+		if err != nil {
+			return clone, err
+		}
 	}
 
 	log.Debugf("powering on %s", name)
 	task, err = vm.PowerOn(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to power on VM, %v", err)
+		return clone, fmt.Errorf("unable to power on VM, %v", err)
 	}
 
 	err = task.Wait(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("power on task failed, %v", err)
+		return clone, fmt.Errorf("power on task failed, %v", err)
 	}
 
-	return vm, nil
+	if cs.Wait != nil {
+		err = clone.WaitForIPs(ctx, *cs.Wait)
+		if err != nil {
+			return clone, err
+		}
+	}
+
+	return clone, nil
 }
 
-func DeleteVM(vm *object.VirtualMachine) error {
-	ctx := context.TODO()
+// clonedNICs pairs the NICs of the clone with the networks they were added for
+func clonedNICs(ctx context.Context, vm *object.VirtualMachine, networks []object.NetworkReference) ([]NIC, error) {
+	vmProps, err := getProperties(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	devices := object.VirtualDeviceList(vmProps.Config.Hardware.Device).SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(devices) != len(networks) {
+		return nil, fmt.Errorf("VM has %v NICs for %v networks", len(devices), len(networks))
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].GetVirtualDevice().Key < devices[j].GetVirtualDevice().Key
+	})
+
+	var nics []NIC
+	for i, dev := range devices {
+		mac := dev.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard().MacAddress
+		if mac == "" {
+			return nil, fmt.Errorf("NIC %v has no MAC address", i)
+		}
+		nics = append(nics, NIC{MACAddress: mac, Network: networks[i]})
+	}
+	return nics, nil
+}
+
+// setNetworkMetadata writes the cloud-init network metadata for the NICs of the VM
+func setNetworkMetadata(ctx context.Context, vm *object.VirtualMachine, hostname string, nics []NIC, networks []cloudinit.NetworkConfig) error {
+	values := cloudinit.MetadataValues{Hostname: hostname}
+	for i, n := range networks {
+		n.MACAddress = nics[i].MACAddress
+		values.Networks = append(values.Networks, n)
+	}
+	metadata, err := cloudinit.GetMetadata(&values)
+	if err != nil {
+		return err
+	}
+	var config cloudinit.Config
+	err = config.SetCloudInitMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("setting network metadata on %s", hostname)
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{ExtraConfig: config})
+	if err != nil {
+		return fmt.Errorf("unable to reconfigure VM, %v", err)
+	}
+	err = task.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("reconfigure task failed, %v", err)
+	}
+	return nil
+}
+
+// sizeConfigSpec sets the CPUs and memory on the config and returns the disk change, the disk can only grow
+func sizeConfigSpec(config *types.VirtualMachineConfigSpec, devices object.VirtualDeviceList, size configtypes.VMSize) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	config.NumCPUs = size.CPUs
+	config.MemoryMB = size.MemoryMiB
+	if size.DiskGiB == 0 {
+		return nil, nil
+	}
+
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("unable to resize disk, template has no disks")
+	}
+	disk := disks[0].(*types.VirtualDisk)
+	capacityKB := int64(size.DiskGiB) * 1024 * 1024
+	if capacityKB < disk.CapacityInKB {
+		return nil, fmt.Errorf("disk size %vGiB is smaller than the template disk size %vGiB", size.DiskGiB, disk.CapacityInKB/1024/1024)
+	}
+	if capacityKB == disk.CapacityInKB {
+		return nil, nil
+	}
+	disk.CapacityInKB = capacityKB
+	disk.CapacityInBytes = capacityKB * 1024
+	return []types.BaseVirtualDeviceConfigSpec{
+		&types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    disk,
+		},
+	}, nil
+}
+
+// DiskGiB returns the size of the first disk of a VM rounded up to GiB, clones cannot have a smaller disk
+func DiskGiB(ctx context.Context, vm *object.VirtualMachine) (int, error) {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get the devices of VM %v, %v", vm.InventoryPath, err)
+	}
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return 0, fmt.Errorf("VM %v has no disks", vm.InventoryPath)
+	}
+	const kiBPerGiB = 1024 * 1024
+	return int((disks[0].(*types.VirtualDisk).CapacityInKB + kiBPerGiB - 1) / kiBPerGiB), nil
+}
+
+func DeleteVM(ctx context.Context, vm *object.VirtualMachine) error {
 
 	// Verify that the VM exists
-	exists, err := vmExists(vm)
+	exists, err := vmExists(ctx, vm)
 	if err != nil {
 		return err
 	}
@@ -113,7 +261,7 @@ func DeleteVM(vm *object.VirtualMachine) error {
 	}
 
 	// Check for tasks
-	vmTasks, err := getTasksForVM(vm)
+	vmTasks, err := getTasksForVM(ctx, vm)
 	if err != nil {
 		return fmt.Errorf("could not get vm tasks, %v", err)
 	}
@@ -121,7 +269,7 @@ func DeleteVM(vm *object.VirtualMachine) error {
 	// Cancel running tasks, if any
 	if len(vmTasks) > 0 {
 		log.Debugf("Found %d tasks for VM %s", len(vmTasks), vm.InventoryPath)
-		err = cancelRunningTasks(vm.Client(), vmTasks)
+		err = cancelRunningTasks(ctx, vm.Client(), vmTasks)
 		if err != nil {
 			return fmt.Errorf("could not cancel tasks for vm %s, %v", vm.InventoryPath, err)
 		}
@@ -132,7 +280,7 @@ func DeleteVM(vm *object.VirtualMachine) error {
 			maxTries := 10
 			for tryCount := 0; tryCount < maxTries; tryCount++ {
 				log.Debugf("Checking if VM %s exists after cancelling creation task", vm.InventoryPath)
-				exists, err = vmExists(vm)
+				exists, err = vmExists(ctx, vm)
 				if err != nil {
 					log.Errorf("Could not check if VM %s exists", vm.InventoryPath)
 				}
@@ -141,14 +289,18 @@ func DeleteVM(vm *object.VirtualMachine) error {
 					log.Debugf("VM %s deleted after cancelling creation task", vm.InventoryPath)
 					return nil
 				}
-				time.Sleep(2 * time.Second)
+				select {
+				case <-time.After(2 * time.Second):
+				case <-ctx.Done():
+					return fmt.Errorf("unable to delete VM %s, %v", vm.InventoryPath, ctx.Err())
+				}
 			}
 			log.Debugf("Wait for VM %s to be deleted after cancelling creation task timed out", vm.InventoryPath)
 		}
 	}
 
 	// Double check that VM is there
-	exists, err = vmExists(vm)
+	exists, err = vmExists(ctx, vm)
 	if err != nil {
 		return err
 	}
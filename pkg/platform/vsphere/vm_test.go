@@ -0,0 +1,214 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	configtypes "github.com/netapp/cake/pkg/config/types"
+	"github.com/netapp/cake/pkg/platform/vsphere/cloudinit"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSizeConfigSpec(t *testing.T) {
+	devices := func() object.VirtualDeviceList {
+		return object.VirtualDeviceList{&types.VirtualDisk{CapacityInKB: 25 * 1024 * 1024}}
+	}
+
+	config := &types.VirtualMachineConfigSpec{}
+	changes, err := sizeConfigSpec(config, devices(), configtypes.VMSize{CPUs: 4, MemoryMiB: 8192, DiskGiB: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.NumCPUs != 4 || config.MemoryMB != 8192 {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected a disk change, got %v", len(changes))
+	}
+	disk := changes[0].GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk)
+	if disk.CapacityInKB != 50*1024*1024 {
+		t.Fatalf("unexpected disk capacity %v", disk.CapacityInKB)
+	}
+
+	changes, err = sizeConfigSpec(&types.VirtualMachineConfigSpec{}, devices(), configtypes.VMSize{CPUs: 2})
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("expected no disk change, got %v, %v", changes, err)
+	}
+
+	_, err = sizeConfigSpec(&types.VirtualMachineConfigSpec{}, devices(), configtypes.VMSize{DiskGiB: 10})
+	if err == nil {
+		t.Fatal("expected a disk smaller than the template to fail")
+	}
+}
+
+func TestDiskGiB(t *testing.T) {
+	ctx := context.Background()
+	_, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	devices, err := template.Device(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk := devices.SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
+	disk.CapacityInKB = 20*1024*1024 + 1
+	err = template.EditDevice(ctx, disk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := DiskGiB(ctx, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 21 {
+		t.Fatalf("expected the disk size rounded up to 21GiB, got %v", size)
+	}
+}
+
+func TestCloneTemplateStaticIP(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	network := cloudinit.NetworkConfig{
+		IPAddress:   "10.0.0.10",
+		Netmask:     "255.255.255.0",
+		Gateway:     "10.0.0.1",
+		NameServers: []string{"10.0.0.2"},
+	}
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "static-0", OSUser: "capv", Networks: []cloudinit.NetworkConfig{network}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props, err := getProperties(ctx, vm.VirtualMachine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata string
+	for _, o := range props.Config.ExtraConfig {
+		if v := o.GetOptionValue(); v.Key == "guestinfo.metadata" {
+			metadata = v.Value.(string)
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Hostname string `yaml:"local-hostname"`
+		Network  struct {
+			Config []struct {
+				MACAddress string `yaml:"mac_address"`
+				Subnets    []struct {
+					Type    string `yaml:"type"`
+					Address string `yaml:"address"`
+				} `yaml:"subnets"`
+			} `yaml:"config"`
+		} `yaml:"network"`
+	}
+	err = yaml.Unmarshal(b, &parsed)
+	if err != nil {
+		t.Fatalf("invalid metadata, %v:\n%s", err, b)
+	}
+
+	mac := vm.NICs[0].MACAddress
+	config := parsed.Network.Config
+	if parsed.Hostname != "static-0" || len(config) != 1 || config[0].MACAddress != mac {
+		t.Fatalf("unexpected metadata for MAC %v:\n%s", mac, b)
+	}
+	if len(config[0].Subnets) != 1 || config[0].Subnets[0].Type != "static" || config[0].Subnets[0].Address != "10.0.0.10" {
+		t.Fatalf("unexpected subnets:\n%s", b)
+	}
+}
+
+func TestCloneTemplateCancelledDuringClone(t *testing.T) {
+	r, template, cleanup := newDelayedSimulatorResource(t, simulator.DelayConfig{MethodDelay: map[string]int{"CloneVM_Task": 500}})
+	defer cleanup()
+
+	// ctx ends while vcsim runs the clone, which completes within the delayed call
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(100*time.Millisecond, cancel)
+	defer timer.Stop()
+	vm, err := r.cloneTemplate(ctx, context.Background(), template, CloneSpec{Name: "cancelled-clone"})
+	if err == nil {
+		t.Fatal("expected the clone to fail once ctx is done")
+	}
+	if vm == nil {
+		t.Fatalf("expected the VM created by the clone task to be returned, %v", err)
+	}
+	found, err := r.SessionManager.GetVM(context.Background(), r.Datacenter, "cancelled-clone")
+	if err != nil || found.Reference() != vm.Reference() {
+		t.Fatalf("expected the returned VM to be the clone, %v", err)
+	}
+}
+
+func TestCloneTemplateWaitTimeout(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	// vcsim reports no guest addresses, so the wait runs out
+	spec := CloneSpec{Name: "timeout-0", Wait: &WaitOptions{Timeout: 500 * time.Millisecond, CIDRs: []string{"192.0.2.0/24"}}}
+	vm, err := r.CloneTemplate(ctx, template, spec)
+	if err == nil || vm != nil {
+		t.Fatalf("expected the wait to time out, got %v, %v", vm, err)
+	}
+	if _, err := r.SessionManager.GetVM(ctx, r.Datacenter, "timeout-0"); err == nil {
+		t.Fatal("VM left behind after the failed wait")
+	}
+}
+
+func TestCloneTemplateInvalidNetwork(t *testing.T) {
+	ctx := context.Background()
+	r := &Resource{}
+	_, err := r.CloneTemplate(ctx, nil, CloneSpec{Name: "invalid", Networks: []cloudinit.NetworkConfig{{IPAddress: "10.0.0.300/24"}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid network 0") {
+		t.Fatalf("expected an invalid network error, got %v", err)
+	}
+}
+
+func TestCloneTemplateNetworks(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	storage, err := r.SessionManager.GetNetwork(ctx, r.Datacenter, "DC0_DVPG0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Networks = []object.NetworkReference{r.Network, storage}
+
+	configs := []cloudinit.NetworkConfig{{DHCP4: true}, {IPAddress: "10.1.0.10/24"}}
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "multi-nic-0", Networks: configs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// vcsim derives every MAC of a VM from its UUID, so only the order of the networks is checked
+	if len(vm.NICs) != 2 || vm.NICs[0].MACAddress == "" {
+		t.Fatalf("unexpected NICs: %+v", vm.NICs)
+	}
+	if vm.NICs[0].Network.Reference() != r.Network.Reference() || vm.NICs[1].Network.Reference() != storage.Reference() {
+		t.Fatalf("unexpected NIC networks: %+v", vm.NICs)
+	}
+	props, err := getProperties(ctx, vm.VirtualMachine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := object.VirtualDeviceList(props.Config.Hardware.Device).SelectByType((*types.VirtualEthernetCard)(nil))
+	if _, ok := devices[1].GetVirtualDevice().Backing.(*types.VirtualEthernetCardDistributedVirtualPortBackingInfo); !ok {
+		t.Fatalf("expected the second NIC on the distributed port group, got %T", devices[1].GetVirtualDevice().Backing)
+	}
+
+	_, err = r.CloneTemplate(ctx, template, CloneSpec{Name: "multi-nic-1", Networks: configs[:1]})
+	if err == nil {
+		t.Fatal("expected a network config count mismatch to fail")
+	}
+}
@@ -0,0 +1,111 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const defaultGuestTimeout = 5 * time.Minute
+
+// WaitOptions controls waiting for the guest of a cloned VM to come up
+type WaitOptions struct {
+	// Timeout for tools and the IP addresses, defaults to 5 minutes
+	Timeout time.Duration
+	// IncludeLinkLocal counts 169.254.0.0/16 and fe80::/10 addresses
+	IncludeLinkLocal bool
+	// CIDRs only counts addresses in one of the CIDRs, any address when empty
+	CIDRs []string
+}
+
+// ipFilter returns whether an address reported by the guest counts
+func (o WaitOptions) ipFilter() (func(string) bool, error) {
+	var networks []*net.IPNet
+	for _, cidr := range o.CIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %v, %v", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return func(address string) bool {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return false
+		}
+		if !o.IncludeLinkLocal && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+			return false
+		}
+		if len(networks) == 0 {
+			return true
+		}
+		for _, n := range networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// WaitForIPs waits until VMware Tools runs in the guest and every NIC has an address,
+// the addresses are set on the NICs
+func (c *ClonedVM) WaitForIPs(ctx context.Context, opts WaitOptions) error {
+	keep, err := opts.ipFilter()
+	if err != nil {
+		return err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultGuestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var toolsRunning bool
+	var guestNICs []types.GuestNicInfo
+	pc := property.DefaultCollector(c.Client())
+	log.Debugf("waiting for tools and IP addresses on %s", c.InventoryPath)
+	err = property.Wait(ctx, pc, c.Reference(), []string{"guest.toolsRunningStatus", "guest.net"}, func(changes []types.PropertyChange) bool {
+		for _, change := range changes {
+			switch change.Name {
+			case "guest.toolsRunningStatus":
+				status, _ := change.Val.(string)
+				toolsRunning = status == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+			case "guest.net":
+				nics, _ := change.Val.(types.ArrayOfGuestNicInfo)
+				guestNICs = nics.GuestNicInfo
+			}
+		}
+		if !toolsRunning {
+			return false
+		}
+
+		addresses := map[string][]string{}
+		for _, n := range guestNICs {
+			for _, ip := range n.IpAddress {
+				if keep(ip) {
+					addresses[n.MacAddress] = append(addresses[n.MacAddress], ip)
+				}
+			}
+		}
+		for _, nic := range c.NICs {
+			if len(addresses[nic.MACAddress]) == 0 {
+				return false
+			}
+		}
+		for i := range c.NICs {
+			c.NICs[i].IPAddresses = addresses[c.NICs[i].MACAddress]
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("unable to wait for tools and IP addresses on %v, %v", c.InventoryPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// setGuest fakes the guest reporting tools running and an address on the first NIC of the clone
+func setGuest(vm *ClonedVM, ip string) {
+	obj := simulator.Map.Get(vm.Reference()).(*simulator.VirtualMachine)
+	simulator.Map.WithLock(obj, func() {
+		nets := append([]types.GuestNicInfo(nil), obj.Guest.Net...)
+		for i := range nets {
+			if nets[i].MacAddress == vm.NICs[0].MACAddress {
+				nets[i].IpAddress = []string{ip}
+			}
+		}
+		simulator.Map.Update(obj, []types.PropertyChange{
+			{Name: "guest.toolsRunningStatus", Val: string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)},
+			{Name: "guest.net", Val: nets},
+		})
+	})
+}
+
+func TestWaitForIPs(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "wait-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		setGuest(vm, "10.0.0.20")
+	}()
+
+	err = vm.WaitForIPs(ctx, WaitOptions{Timeout: 10 * time.Second, CIDRs: []string{"10.0.0.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vm.NICs[0].IPAddresses) != 1 || vm.NICs[0].IPAddresses[0] != "10.0.0.20" {
+		t.Fatalf("unexpected addresses: %v", vm.NICs[0].IPAddresses)
+	}
+}
+
+func TestWaitForIPsLinkLocal(t *testing.T) {
+	ctx := context.Background()
+	r, template, cleanup := newSimulatorResource(t)
+	defer cleanup()
+
+	vm, err := r.CloneTemplate(ctx, template, CloneSpec{Name: "wait-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGuest(vm, "169.254.10.1")
+
+	err = vm.WaitForIPs(ctx, WaitOptions{Timeout: 500 * time.Millisecond})
+	if err == nil {
+		t.Fatalf("expected the link-local address to be ignored, got %v", vm.NICs[0].IPAddresses)
+	}
+	err = vm.WaitForIPs(ctx, WaitOptions{Timeout: 5 * time.Second, IncludeLinkLocal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	keep, err := WaitOptions{CIDRs: []string{"192.168.1.0/24", "fd00::/8"}}.ipFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"192.168.1.5": true,
+		"192.168.2.5": false,
+		"fd00::1":     true,
+		"fe80::1":     false,
+		"not-an-ip":   false,
+	} {
+		if keep(ip) != want {
+			t.Errorf("%v: expected %v", ip, want)
+		}
+	}
+	if _, err := (WaitOptions{CIDRs: []string{"10.0.0.0"}}).ipFilter(); err == nil {
+		t.Error("expected an invalid CIDR to fail")
+	}
+}